@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	alpm "github.com/jguer/go-alpm"
+)
+
+// absPkg is a repo package whose PKGBUILD is being downloaded.
+type absPkg struct {
+	Base    string
+	Repo    string
+	Version string
+}
+
+// absSource downloads the PKGBUILD of a repo package into dest.
+type absSource interface {
+	fetch(pkg absPkg, dest string) error
+}
+
+// selectABSSource picks how PKGBUILDs are downloaded from config.ABSURL. A
+// URL ending in .tar.gz is downloaded as a snapshot, anything else is cloned
+// with git.
+func selectABSSource() absSource {
+	if strings.HasSuffix(config.ABSURL, ".tar.gz") {
+		return archiveSource{config.ABSURL}
+	}
+
+	return gitSource{config.ABSURL}
+}
+
+// expandABSURL fills in the {pkgbase} and {repo} placeholders of template.
+func expandABSURL(template string, pkg absPkg) string {
+	return strings.NewReplacer("{pkgbase}", pkg.Base, "{repo}", pkg.Repo).Replace(template)
+}
+
+// absTag returns the git tag of a package version. Tags can not contain a
+// colon so the epoch is separated with a dash instead.
+func absTag(version string) string {
+	return strings.Replace(version, ":", "-", 1)
+}
+
+// gitSource clones a git repo per package and checks out the tag of the
+// version in the sync database.
+type gitSource struct {
+	template string
+}
+
+func (s gitSource) fetch(pkg absPkg, dest string) error {
+	url := expandABSURL(s.template, pkg)
+
+	if dirExists(filepath.Join(dest, ".git")) {
+		_, stderr, err := capture(passToGit(dest, "fetch", "--tags"))
+		if err != nil {
+			return fmt.Errorf("error fetching %s: %s", pkg.Base, strings.TrimSpace(stderr))
+		}
+	} else {
+		cmd := passToGit(filepath.Dir(dest), "clone", "--no-progress", url, filepath.Base(dest))
+		_, stderr, err := capture(cmd)
+		if err != nil {
+			return fmt.Errorf("error cloning %s from %s: %s", pkg.Base, url, strings.TrimSpace(stderr))
+		}
+	}
+
+	// An existing clone is moved to the fetched tag or branch just like a
+	// new one. Local changes in the way make the checkout fail rather than
+	// being thrown away.
+	ref := absTag(pkg.Version)
+	if _, _, err := capture(passToGit(dest, "rev-parse", "--verify", "--quiet", "refs/tags/"+ref)); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s has no tag for %s, using the default branch\n", bold(yellow(smallArrow)), cyan(pkg.Base), pkg.Version)
+		ref = "origin/HEAD"
+	}
+
+	_, stderr, err := capture(passToGit(dest, "checkout", "--quiet", ref))
+	if err != nil {
+		return fmt.Errorf("error checking out %s in %s: %s", ref, pkg.Base, strings.TrimSpace(stderr))
+	}
+
+	return nil
+}
+
+// archiveSource downloads a tarball per package and keeps the directory in
+// it holding the PKGBUILD. Snapshots holding a trunk directory, like the ones
+// from svntogit, use the PKGBUILD from trunk.
+type archiveSource struct {
+	template string
+}
+
+func (s archiveSource) fetch(pkg absPkg, dest string) error {
+	// Unpack next to dest so the result can be renamed into place.
+	tmp, err := ioutil.TempDir(filepath.Dir(dest), ".yay-abs-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	url := expandABSURL(s.template, pkg)
+	if err = downloadAndUnpack(url, tmp); err != nil {
+		return fmt.Errorf("error downloading %s from %s: %s", pkg.Base, url, err)
+	}
+
+	found := ""
+	err = filepath.Walk(tmp, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || info.Name() != "PKGBUILD" {
+			return err
+		}
+
+		dir := filepath.Dir(path)
+		if found == "" || filepath.Base(dir) == "trunk" {
+			found = dir
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if found == "" {
+		return fmt.Errorf("no PKGBUILD found in %s", url)
+	}
+
+	return os.Rename(found, dest)
+}
+
+func dirExists(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && stat.IsDir()
+}
+
+// findSyncPkg finds a package in the sync databases. A "repo/name" target
+// only looks in that repo.
+func findSyncPkg(target string) (*alpm.Package, error) {
+	dbName, name := splitDbFromName(target)
+
+	if dbName != "" {
+		db, err := alpmHandle.SyncDbByName(dbName)
+		if err != nil {
+			return nil, fmt.Errorf("%s: repo %s not found", target, dbName)
+		}
+
+		pkg, err := db.PkgByName(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: package not found in %s", target, dbName)
+		}
+
+		return pkg, nil
+	}
+
+	dbList, err := alpmHandle.SyncDbs()
+	if err != nil {
+		return nil, err
+	}
+
+	var pkg *alpm.Package
+	dbList.ForEach(func(db alpm.Db) error {
+		if p, err := db.PkgByName(name); err == nil {
+			pkg = p
+			return fmt.Errorf("")
+		}
+		return nil
+	})
+
+	if pkg == nil {
+		return nil, fmt.Errorf("%s: package not found in the sync databases", target)
+	}
+
+	return pkg, nil
+}
+
+// getPkgbuildsfromABS downloads the PKGBUILDs of repo packages into path. An
+// error is returned for every package that could not be downloaded.
+func getPkgbuildsfromABS(targets []string, path string) error {
+	var wg sync.WaitGroup
+	var mux sync.Mutex
+	var errs MultiError
+	source := selectABSSource()
+	pkgs := make([]absPkg, 0, len(targets))
+	seen := make(stringSet)
+	downloaded := 0
+
+	for _, target := range targets {
+		pkg, err := findSyncPkg(target)
+		if err != nil {
+			errs.Add(err)
+			continue
+		}
+
+		base := pkg.Base()
+		if base == "" {
+			base = pkg.Name()
+		}
+		if seen.get(base) {
+			continue
+		}
+		seen.set(base)
+
+		dest := filepath.Join(path, base)
+		_, isGit := source.(gitSource)
+		_, err = os.Stat(dest)
+		if err != nil && !os.IsNotExist(err) {
+			errs.Add(fmt.Errorf("%s: %s", base, err))
+			continue
+		} else if err == nil && cmdArgs.existsArg("f", "force") {
+			if err = os.RemoveAll(dest); err != nil {
+				errs.Add(fmt.Errorf("%s: %s", base, err))
+				continue
+			}
+		} else if err == nil && !(isGit && dirExists(filepath.Join(dest, ".git"))) {
+			fmt.Printf("%s %s %s\n", yellow(smallArrow), cyan(base), "already downloaded -- use -f to overwrite")
+			continue
+		}
+
+		pkgs = append(pkgs, absPkg{base, pkg.DB().Name(), pkg.Version()})
+	}
+
+	download := func(pkg absPkg) {
+		defer wg.Done()
+		err := source.fetch(pkg, filepath.Join(path, pkg.Base))
+
+		mux.Lock()
+		downloaded++
+		if err != nil {
+			errs.Add(err)
+		} else {
+			fmt.Printf(bold(cyan("::"))+" Downloaded PKGBUILD from ABS (%d/%d): %s\n", downloaded, len(pkgs), cyan(pkg.Base))
+		}
+		mux.Unlock()
+	}
+
+	for k, pkg := range pkgs {
+		wg.Add(1)
+		go download(pkg)
+		if (k+1)%25 == 0 {
+			wg.Wait()
+		}
+	}
+
+	wg.Wait()
+	return errs.Return()
+}
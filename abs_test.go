@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestExpandABSURL(t *testing.T) {
+	testCases := []struct {
+		template string
+		pkg      absPkg
+		url      string
+		tag      string
+	}{
+		{
+			"https://gitlab.archlinux.org/archlinux/packaging/packages/{pkgbase}.git",
+			absPkg{"linux", "core-testing", "6.1.1.arch1-1"},
+			"https://gitlab.archlinux.org/archlinux/packaging/packages/linux.git",
+			"6.1.1.arch1-1",
+		},
+		{
+			"https://example.com/{repo}/{pkgbase}.tar.gz",
+			absPkg{"vim", "extra-staging", "2:9.0-1"},
+			"https://example.com/extra-staging/vim.tar.gz",
+			"2-9.0-1",
+		},
+	}
+
+	for _, tc := range testCases {
+		if url := expandABSURL(tc.template, tc.pkg); url != tc.url {
+			t.Fatalf("expected %s, got %s", tc.url, url)
+		}
+		if tag := absTag(tc.pkg.Version); tag != tc.tag {
+			t.Fatalf("expected tag %s, got %s", tc.tag, tag)
+		}
+	}
+}
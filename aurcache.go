@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	rpc "github.com/mikkeloscar/aur"
+)
+
+// aurCacheMaxAge is how old cached AUR data can be before it is reported as
+// stale in offline mode.
+const aurCacheMaxAge = 24 * time.Hour
+
+// aurCacheEntry is a package as last returned by the AUR along with the time
+// it was fetched.
+type aurCacheEntry struct {
+	Pkg  rpc.Pkg `json:"pkg"`
+	Time int64   `json:"time"`
+}
+
+// aurCache keeps every package returned by an info request on disk so it can
+// be used in offline mode.
+type aurCache struct {
+	path string
+
+	mux     sync.Mutex
+	loaded  bool
+	entries map[string]aurCacheEntry
+}
+
+func (c *aurCache) load() error {
+	if c.loaded {
+		return nil
+	}
+
+	c.entries = make(map[string]aurCacheEntry)
+	c.loaded = true
+
+	cfile, err := os.Open(c.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Failed to open AUR cache '%s': %s", c.path, err)
+	}
+	defer cfile.Close()
+
+	decoder := json.NewDecoder(cfile)
+	if err = decoder.Decode(&c.entries); err != nil {
+		return fmt.Errorf("Failed to read AUR cache '%s': %s", c.path, err)
+	}
+
+	return nil
+}
+
+func (c *aurCache) save() error {
+	marshalledinfo, err := json.MarshalIndent(c.entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	in, err := os.OpenFile(c.path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_, err = in.Write(marshalledinfo)
+	if err != nil {
+		return err
+	}
+	err = in.Sync()
+	return err
+}
+
+// add stores pkgs as fetched now.
+func (c *aurCache) add(pkgs []rpc.Pkg) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if err := c.load(); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for _, pkg := range pkgs {
+		c.entries[pkg.Name] = aurCacheEntry{pkg, now}
+	}
+
+	return c.save()
+}
+
+// all returns every cached entry.
+func (c *aurCache) all() (map[string]aurCacheEntry, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	return c.entries, nil
+}
+
+// cachingBackend saves the info results of another backend to the AUR cache.
+type cachingBackend struct {
+	aurBackend
+	cache *aurCache
+}
+
+func (b cachingBackend) Info(names []string) ([]rpc.Pkg, error) {
+	pkgs, err := b.aurBackend.Info(names)
+	if err != nil {
+		return pkgs, err
+	}
+
+	if err := b.cache.add(pkgs); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	return pkgs, nil
+}
+
+// offlineBackend answers queries from the AUR cache, falling back to the
+// .SRCINFO files of bases already in the build dir. Anything older than
+// aurCacheMaxAge or only found in the build dir is reported once.
+type offlineBackend struct {
+	cache *aurCache
+
+	once  sync.Once
+	build []rpc.Pkg
+	err   error
+
+	mux    sync.Mutex
+	warned stringSet
+}
+
+func (o *offlineBackend) buildPkgs() ([]rpc.Pkg, error) {
+	o.once.Do(func() {
+		o.build, o.err = readSrcinfoTree(config.BuildDir, localArch())
+	})
+
+	return o.build, o.err
+}
+
+// pkgs merges the cache and the build dir, preferring the cache. The returned
+// map holds when each package was fetched, zero for the build dir.
+func (o *offlineBackend) pkgs() ([]rpc.Pkg, map[string]int64, error) {
+	entries, err := o.cache.all()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	build, err := o.buildPkgs()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pkgs := make([]rpc.Pkg, 0, len(entries)+len(build))
+	times := make(map[string]int64, len(entries)+len(build))
+
+	for name, entry := range entries {
+		pkgs = append(pkgs, entry.Pkg)
+		times[name] = entry.Time
+	}
+
+	for _, pkg := range build {
+		if _, ok := times[pkg.Name]; !ok {
+			pkgs = append(pkgs, pkg)
+			times[pkg.Name] = 0
+		}
+	}
+
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Name < pkgs[j].Name })
+	return pkgs, times, nil
+}
+
+// warnStale reports the packages in pkgs whose data is stale to w.
+func (o *offlineBackend) warnStale(w io.Writer, pkgs []rpc.Pkg, times map[string]int64) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	if o.warned == nil {
+		o.warned = make(stringSet)
+	}
+
+	stale := make([]string, 0)
+	build := make([]string, 0)
+	now := time.Now()
+
+	for _, pkg := range pkgs {
+		if o.warned.get(pkg.Name) {
+			continue
+		}
+
+		if times[pkg.Name] == 0 {
+			build = append(build, pkg.Name)
+			o.warned.set(pkg.Name)
+		} else if age := now.Sub(time.Unix(times[pkg.Name], 0)); age > aurCacheMaxAge {
+			stale = append(stale, fmt.Sprintf("%s (%s)", pkg.Name, formatAge(age)))
+			o.warned.set(pkg.Name)
+		}
+	}
+
+	if len(stale) > 0 {
+		fmt.Fprintln(w, bold(yellow(arrow)), bold("Offline: stale AUR data for:"), strings.Join(stale, " "))
+	}
+	if len(build) > 0 {
+		fmt.Fprintln(w, bold(yellow(arrow)), bold("Offline: AUR data from build files for:"), strings.Join(build, " "))
+	}
+}
+
+func (o *offlineBackend) Info(names []string) ([]rpc.Pkg, error) {
+	pkgs, times, err := o.pkgs()
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := sliceToStringSet(names)
+	info := make([]rpc.Pkg, 0, len(names))
+	for _, pkg := range pkgs {
+		if wanted.get(pkg.Name) {
+			info = append(info, pkg)
+		}
+	}
+
+	o.warnStale(os.Stderr, info, times)
+	return info, nil
+}
+
+func (o *offlineBackend) Search(query string) ([]rpc.Pkg, error) {
+	pkgs, _, err := o.pkgs()
+	if err != nil {
+		return nil, err
+	}
+
+	return searchPkgs(pkgs, query), nil
+}
+
+func (o *offlineBackend) Provides(name string) ([]rpc.Pkg, error) {
+	pkgs, _, err := o.pkgs()
+	if err != nil {
+		return nil, err
+	}
+
+	return providesPkgs(pkgs, name), nil
+}
+
+// formatAge describes a duration in the largest whole unit.
+func formatAge(age time.Duration) string {
+	switch {
+	case age >= 48*time.Hour:
+		return fmt.Sprintf("%d days old", int(age.Hours()/24))
+	case age >= 2*time.Hour:
+		return fmt.Sprintf("%d hours old", int(age.Hours()))
+	}
+	return fmt.Sprintf("%d minutes old", int(age.Minutes()))
+}
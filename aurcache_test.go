@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	rpc "github.com/mikkeloscar/aur"
+)
+
+func TestAURCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yay-aurcache")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "aur.json")
+	local := &localBackend{pkgs: []rpc.Pkg{
+		{Name: "foo", Version: "1.0-1"},
+		{Name: "bar", Version: "2.0-1"},
+	}}
+	local.once.Do(func() {})
+
+	backend := cachingBackend{local, &aurCache{path: path}}
+	if _, err = backend.Info([]string{"foo", "missing"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, err = backend.Info([]string{"bar"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	entries, err := (&aurCache{path: path}).all()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(entries) != 2 || entries["foo"].Pkg.Version != "1.0-1" || entries["bar"].Pkg.Version != "2.0-1" {
+		t.Fatalf("Unexpected entries %v", entries)
+	}
+	if age := time.Since(time.Unix(entries["foo"].Time, 0)); age > time.Minute {
+		t.Fatalf("Expected foo to be fetched just now, got %s ago", age)
+	}
+
+	ioutil.WriteFile(path, []byte("{"), 0644)
+	if _, err = (&aurCache{path: path}).all(); err == nil {
+		t.Fatalf("Expected an error for a corrupt cache")
+	}
+}
+
+func TestOfflineBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yay-offline")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldConfig := config
+	defer func() { config = oldConfig }()
+	config = defaultSettings()
+	config.BuildDir = dir
+	useColor = false
+
+	os.MkdirAll(filepath.Join(dir, "foo"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "foo", ".SRCINFO"), []byte(testSrcinfo), 0644)
+
+	now := time.Now()
+	cache := &aurCache{
+		loaded: true,
+		entries: map[string]aurCacheEntry{
+			"foo": {rpc.Pkg{Name: "foo", PackageBase: "foo", Version: "2.0-1"}, now.Unix()},
+			"bar": {rpc.Pkg{Name: "bar", PackageBase: "bar", Version: "1.0-1", Description: "Frobnicates the widgets"}, now.Add(-72 * time.Hour).Unix()},
+		},
+	}
+
+	testCases := []struct {
+		names    []string
+		versions []string
+		warnings string
+	}{
+		{
+			[]string{"foo", "foo-docs", "bar", "missing"},
+			[]string{"bar 1.0-1", "foo 2.0-1", "foo-docs 1.0-2"},
+			"==> Offline: stale AUR data for: bar (3 days old)\n" +
+				"==> Offline: AUR data from build files for: foo-docs\n",
+		},
+		{
+			[]string{"foo"},
+			[]string{"foo 2.0-1"},
+			"",
+		},
+	}
+
+	for _, tc := range testCases {
+		offline := &offlineBackend{cache: cache}
+		pkgs, times, err := offline.pkgs()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		info, err := offline.Info(tc.names)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		versions := make([]string, 0, len(info))
+		for _, pkg := range info {
+			versions = append(versions, pkg.Name+" "+pkg.Version)
+		}
+		if !reflect.DeepEqual(versions, tc.versions) {
+			t.Fatalf("%v: expected %v got %v", tc.names, tc.versions, versions)
+		}
+
+		if len(pkgs) != 3 {
+			t.Fatalf("Expected the cache and build dir to be merged, got %v", pkgs)
+		}
+
+		var warnings bytes.Buffer
+		fresh := &offlineBackend{cache: cache}
+		fresh.warnStale(&warnings, info, times)
+		if warnings.String() != tc.warnings {
+			t.Fatalf("%v: expected warnings %q got %q", tc.names, tc.warnings, warnings.String())
+		}
+
+		// Each package is only reported once, Info has already done so
+		for _, backend := range []*offlineBackend{fresh, offline} {
+			warnings.Reset()
+			backend.warnStale(&warnings, info, times)
+			if warnings.Len() != 0 {
+				t.Fatalf("%v: expected no more warnings, got %q", tc.names, warnings.String())
+			}
+		}
+	}
+
+	// The cached foo has no description so only bar matches
+	results, err := (&offlineBackend{cache: cache}).Search("widgets")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(results) != 1 || results[0].Name != "bar" {
+		t.Fatalf("Expected bar, got %v", results)
+	}
+
+	results, _ = (&offlineBackend{cache: cache}).Search("documentation")
+	if len(results) != 1 || results[0].Name != "foo-docs" {
+		t.Fatalf("Expected foo-docs from the build dir, got %v", results)
+	}
+}
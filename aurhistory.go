@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	gosrc "github.com/Morganamilo/go-srcinfo"
+	alpm "github.com/jguer/go-alpm"
+	rpc "github.com/mikkeloscar/aur"
+)
+
+// srcinfoRevision is a commit of an AUR repo along with the version its
+// .SRCINFO declares.
+type srcinfoRevision struct {
+	Commit  string
+	Date    int64
+	Version string
+}
+
+// parseVersionTarget splits a target such as "foo=1.2-1" or "foo==1.2-1"
+// into the package name and version. The version is empty if the target has none.
+func parseVersionTarget(target string) (string, string) {
+	split := strings.SplitN(target, "=", 2)
+	if len(split) == 2 {
+		return split[0], strings.TrimPrefix(split[1], "=")
+	}
+
+	return target, ""
+}
+
+// parseCatFileBatch splits the output of git cat-file --batch into the
+// contents of each object. Missing objects are returned as nil.
+func parseCatFileBatch(out []byte) ([][]byte, error) {
+	objects := make([][]byte, 0)
+
+	for len(out) > 0 {
+		i := bytes.IndexByte(out, '\n')
+		if i == -1 {
+			return nil, fmt.Errorf("truncated cat-file header")
+		}
+
+		header := strings.Fields(string(out[:i]))
+		out = out[i+1:]
+
+		if len(header) == 2 && header[1] == "missing" {
+			objects = append(objects, nil)
+			continue
+		} else if len(header) != 3 {
+			return nil, fmt.Errorf("invalid cat-file header: %s", strings.Join(header, " "))
+		}
+
+		size, err := strconv.Atoi(header[2])
+		if err != nil || size+1 > len(out) {
+			return nil, fmt.Errorf("invalid cat-file header: %s", strings.Join(header, " "))
+		}
+
+		objects = append(objects, out[:size])
+		out = out[size+1:]
+	}
+
+	return objects, nil
+}
+
+// srcinfoHistory lists every commit reachable from rev that changed the
+// .SRCINFO of the repo in dir, newest first. Commits whose .SRCINFO can not
+// be parsed are left out.
+func srcinfoHistory(dir string, rev string) ([]srcinfoRevision, error) {
+	name := filepath.Base(dir)
+	stdout, stderr, err := capture(passToGit(dir, "log", "--format=%H %ct", rev, "--", ".SRCINFO"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading the history of %s: %s", name, stderr)
+	}
+
+	commits := make([]srcinfoRevision, 0)
+	var objects bytes.Buffer
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		date, _ := strconv.ParseInt(fields[1], 10, 64)
+		commits = append(commits, srcinfoRevision{Commit: fields[0], Date: date})
+		fmt.Fprintf(&objects, "%s:.SRCINFO\n", fields[0])
+	}
+
+	var outbuf, errbuf bytes.Buffer
+	cmd := passToGit(dir, "cat-file", "--batch")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = &objects, &outbuf, &errbuf
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error reading the history of %s: %s", name, strings.TrimSpace(errbuf.String()))
+	}
+
+	contents, err := parseCatFileBatch(outbuf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error reading the history of %s: %s", name, err)
+	}
+	if len(contents) != len(commits) {
+		return nil, fmt.Errorf("error reading the history of %s: expected %d objects, got %d", name, len(commits), len(contents))
+	}
+
+	revs := make([]srcinfoRevision, 0, len(commits))
+	for i, rev := range commits {
+		if contents[i] == nil {
+			continue
+		}
+
+		srcinfo, err := gosrc.Parse(string(contents[i]))
+		if err != nil {
+			continue
+		}
+
+		rev.Version = srcinfo.Version()
+		revs = append(revs, rev)
+	}
+
+	return revs, nil
+}
+
+// findRevision returns the newest revision matching version. A version
+// without a pkgrel matches any pkgrel.
+func findRevision(revs []srcinfoRevision, version string) (srcinfoRevision, bool) {
+	for _, rev := range revs {
+		if alpm.VerCmp(rev.Version, version) == 0 {
+			return rev, true
+		}
+	}
+
+	return srcinfoRevision{}, false
+}
+
+// checkoutAURVersion checks out the newest commit reachable from rev whose
+// .SRCINFO has version.
+func checkoutAURVersion(dir string, rev string, version string) (srcinfoRevision, error) {
+	name := filepath.Base(dir)
+	revs, err := srcinfoHistory(dir, rev)
+	if err != nil {
+		return srcinfoRevision{}, err
+	}
+
+	found, ok := findRevision(revs, version)
+	if !ok {
+		return srcinfoRevision{}, fmt.Errorf("%s: no commit has version %s -- use -G --log %s to list versions", name, version, name)
+	}
+
+	return found, checkoutAURCommit(dir, found.Commit)
+}
+
+func checkoutAURCommit(dir string, commit string) error {
+	_, stderr, err := capture(passToGit(dir, "checkout", "--quiet", commit))
+	if err != nil {
+		return fmt.Errorf("error checking out %s in %s: %s", commit, filepath.Base(dir), stderr)
+	}
+
+	return nil
+}
+
+// getPkgbuildRevisions downloads AUR bases into path and checks out an older
+// revision of each. revisions maps a pkgbase to the version to check out, or
+// commit is checked out if it is set.
+func getPkgbuildRevisions(bases []Base, revisions map[string]string, commit string, path string) error {
+	var errs MultiError
+
+	for _, base := range bases {
+		name := base.Pkgbase()
+		dir := filepath.Join(path, name)
+
+		if err := os.RemoveAll(dir); err != nil {
+			errs.Add(err)
+			continue
+		}
+
+		if _, err := gitDownload(aurGitURL(name), path, name); err != nil {
+			errs.Add(err)
+			continue
+		}
+
+		if commit != "" {
+			if err := checkoutAURCommit(dir, commit); err != nil {
+				errs.Add(err)
+				continue
+			}
+
+			fmt.Println(bold(cyan("::")), bold("Checked out"), cyan(name), bold("at"), commit)
+			continue
+		}
+
+		rev, err := checkoutAURVersion(dir, "HEAD", revisions[name])
+		if err != nil {
+			errs.Add(err)
+			continue
+		}
+
+		fmt.Println(bold(cyan("::")), bold("Checked out"), cyan(name), green(rev.Version), bold("from"), formatTime(int(rev.Date)), rev.Commit)
+	}
+
+	return errs.Return()
+}
+
+// printAURLog lists the versions each target's AUR repo has been at along
+// with the commit that set it. A clone already in wd or the build dir is
+// fetched and used, otherwise the repo is cloned into a temporary directory.
+func printAURLog(targets []string, wd string) error {
+	var errs MultiError
+
+	info, err := aurInfoPrint(targets)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempDir("", "yay-log")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, base := range getBases(info) {
+		name := base.Pkgbase()
+		path := tmp
+		for _, dir := range []string{wd, config.BuildDir} {
+			if dirExists(filepath.Join(dir, name, ".git")) {
+				path = dir
+				break
+			}
+		}
+
+		if _, err = gitDownload(aurGitURL(name), path, name); err != nil {
+			errs.Add(err)
+			continue
+		}
+
+		// HEAD of an existing clone may be at an older commit
+		revs, err := srcinfoHistory(filepath.Join(path, name), "origin/HEAD")
+		if err != nil {
+			errs.Add(err)
+			continue
+		}
+
+		for _, rev := range revs {
+			fmt.Printf("%s %s %s %s\n", bold(name), green(rev.Version), formatTime(int(rev.Date)), rev.Commit)
+		}
+	}
+
+	return errs.Return()
+}
+
+// ensureAURClone makes sure the build dir of pkgbase is an up to date git
+// clone, replacing a directory downloaded as a tarball.
+func ensureAURClone(pkgbase string) error {
+	dir := filepath.Join(config.BuildDir, pkgbase)
+	if !dirExists(filepath.Join(dir, ".git")) {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+
+	_, err := gitDownload(aurGitURL(pkgbase), config.BuildDir, pkgbase)
+	return err
+}
+
+// resolveAURVersions looks up AUR targets asking for an exact version the
+// AUR is not at in the history of their repo. The info of the newest commit
+// with that version is cached in place of the RPC's and the commit is
+// recorded so that it is built instead. A read only solver searches existing
+// clones as they are and otherwise keeps the current dependencies.
+func (ds *depSolver) resolveAURVersions(targets []target) error {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, target.Name)
+	}
+
+	info, err := aurInfo(names, ds.Warnings)
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]*rpc.Pkg)
+	for _, pkg := range info {
+		current[pkg.Name] = pkg
+	}
+
+	for _, target := range targets {
+		pkg, ok := current[target.Name]
+		if !ok || pkgSatisfies(pkg.Name, pkg.Version, target.DepString()) {
+			// Missing targets are reported when checking for missing
+			// dependencies
+			continue
+		}
+
+		base := pkg.PackageBase
+		if rev, ok := ds.Revisions[base]; ok {
+			if alpm.VerCmp(rev.Version, target.Version) != 0 {
+				return fmt.Errorf("%s: %s is already being built at %s", target.String(), base, rev.Version)
+			}
+			continue
+		}
+
+		dir := filepath.Join(config.BuildDir, base)
+		if ds.ReadOnly && !dirExists(filepath.Join(dir, ".git")) {
+			// Without a clone the history can not be read, so assume the
+			// dependencies have not changed since
+			fmt.Fprintln(os.Stderr, bold(yellow(smallArrow)), cyan(target.String())+bold(": dependencies are those of"), cyan(pkg.Version)+bold(", the history of"), cyan(base), bold("is searched when installing"))
+			ds.Revisions[base] = srcinfoRevision{Version: target.Version}
+			old := *pkg
+			old.Version = target.Version
+			ds.AurCache[old.Name] = &old
+			continue
+		}
+
+		fmt.Println(bold(cyan("::")), bold("Searching the history of"), cyan(base), bold("for"), target.Version)
+		if !ds.ReadOnly {
+			if err = ensureAURClone(base); err != nil {
+				return err
+			}
+		}
+
+		revs, err := srcinfoHistory(dir, "HEAD@{upstream}")
+		if err != nil {
+			return err
+		}
+
+		rev, ok := findRevision(revs, target.Version)
+		if !ok {
+			return fmt.Errorf("%s: no commit of %s has version %s", target.String(), base, target.Version)
+		}
+
+		snapshot, err := gitSnapshot(dir, rev.Commit)
+		if err != nil {
+			return fmt.Errorf("error reading %s at %s: %s", base, rev.Commit, err)
+		}
+
+		ds.Revisions[base] = rev
+		for _, old := range srcinfoPkgs(snapshot.srcinfo, localArch()) {
+			old := old
+			old.Maintainer = pkg.Maintainer
+			old.NumVotes = pkg.NumVotes
+			old.Popularity = pkg.Popularity
+			ds.AurCache[old.Name] = &old
+		}
+	}
+
+	return nil
+}
+
+// checkoutAURRevisions moves the build dir of each base back to the commit
+// it was resolved at. The branch is reset rather than detached so the next
+// merge fast forwards it again.
+func checkoutAURRevisions(revisions map[string]srcinfoRevision) error {
+	bases := make([]string, 0, len(revisions))
+	for base := range revisions {
+		bases = append(bases, base)
+	}
+	sort.Strings(bases)
+
+	for _, base := range bases {
+		rev := revisions[base]
+		if err := ensureAURClone(base); err != nil {
+			return err
+		}
+
+		dir := filepath.Join(config.BuildDir, base)
+		_, stderr, err := capture(passToGit(dir, "reset", "--hard", rev.Commit))
+		if err != nil {
+			return fmt.Errorf("error checking out %s in %s: %s", rev.Commit, base, stderr)
+		}
+
+		fmt.Println(bold(cyan("::")), bold("Checked out"), cyan(base), green(rev.Version), bold("from"), formatTime(int(rev.Date)), rev.Commit)
+	}
+
+	return nil
+}
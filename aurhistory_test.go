@@ -0,0 +1,189 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	rpc "github.com/mikkeloscar/aur"
+)
+
+func TestParseCatFileBatch(t *testing.T) {
+	out := []byte("aaaa blob 5\nhello\nbbbb:.SRCINFO missing\ncccc blob 0\n\n")
+
+	objects, err := parseCatFileBatch(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]byte{[]byte("hello"), nil, []byte("")}
+	if !reflect.DeepEqual(objects, expected) {
+		t.Fatalf("expected %q, got %q", expected, objects)
+	}
+
+	if _, err = parseCatFileBatch([]byte("aaaa blob 50\nhello\n")); err == nil {
+		t.Fatalf("expected an error for a truncated object")
+	}
+}
+
+func TestFindRevision(t *testing.T) {
+	revs := []srcinfoRevision{
+		{"c3", 3, "1.1-1"},
+		{"c2", 2, "1.0-2"},
+		{"c1", 1, "1.0-1"},
+		{"c0", 0, "1:0.9-1"},
+	}
+
+	testCases := []struct {
+		version string
+		commit  string
+	}{
+		{"1.0-1", "c1"},
+		{"1.0", "c2"},
+		{"1:0.9-1", "c0"},
+		{"0.9-1", ""},
+		{"2.0", ""},
+	}
+
+	for _, tc := range testCases {
+		rev, ok := findRevision(revs, tc.version)
+		if ok != (tc.commit != "") || rev.Commit != tc.commit {
+			t.Fatalf("%s: expected %q, got %q", tc.version, tc.commit, rev.Commit)
+		}
+	}
+}
+
+func TestParseVersionTarget(t *testing.T) {
+	testCases := []struct {
+		target  string
+		name    string
+		version string
+	}{
+		{"foo", "foo", ""},
+		{"foo=1.0-1", "foo", "1.0-1"},
+		{"foo==1.0-1", "foo", "1.0-1"},
+	}
+
+	for _, tc := range testCases {
+		name, version := parseVersionTarget(tc.target)
+		if name != tc.name || version != tc.version {
+			t.Fatalf("%s: expected %s %s, got %s %s", tc.target, tc.name, tc.version, name, version)
+		}
+	}
+
+	if target := toTarget("aur/foo==1.0"); target.DepString() != "foo=1.0" {
+		t.Fatalf("expected foo=1.0, got %s", target.DepString())
+	}
+}
+
+const historySrcinfo = `pkgbase = foo
+	pkgver = %s
+	pkgrel = 1
+	arch = any
+	depends = %s
+
+pkgname = foo
+`
+
+// makeHistoryRepo creates an AUR style repo of foo in dir/foo with a commit
+// for each version, each depending on the matching dependency.
+func makeHistoryRepo(t *testing.T, dir string, versions []string, depends []string) {
+	repo := filepath.Join(dir, "foo")
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=yay", "GIT_AUTHOR_EMAIL=yay@example.org",
+			"GIT_COMMITTER_NAME=yay", "GIT_COMMITTER_EMAIL=yay@example.org")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %s", strings.Join(args, " "), out)
+		}
+	}
+
+	os.MkdirAll(repo, 0755)
+	git("init", "--quiet")
+	for i, version := range versions {
+		srcinfo := strings.Replace(historySrcinfo, "%s", version, 1)
+		srcinfo = strings.Replace(srcinfo, "%s", depends[i], 1)
+		ioutil.WriteFile(filepath.Join(repo, ".SRCINFO"), []byte(srcinfo), 0644)
+		git("add", ".SRCINFO")
+		git("commit", "--quiet", "-m", version)
+	}
+}
+
+func TestResolveAURVersions(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "yay-history")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldConfig, oldClient := config, aurClient
+	defer func() { config, aurClient = oldConfig, oldClient }()
+
+	config = defaultSettings()
+	config.BuildDir = filepath.Join(dir, "build")
+	os.MkdirAll(config.BuildDir, 0755)
+	aurClient = &localBackend{root: filepath.Join(dir, "aur")}
+	makeHistoryRepo(t, filepath.Join(dir, "aur"), []string{"1.0", "1.1", "2.0"}, []string{"bar", "baz", "qux"})
+
+	newSolver := func(readOnly bool) *depSolver {
+		return &depSolver{
+			AurCache:  make(map[string]*rpc.Pkg),
+			Warnings:  &aurWarnings{},
+			Revisions: make(map[string]srcinfoRevision),
+			ReadOnly:  readOnly,
+		}
+	}
+
+	// Without a clone a read only solver keeps the current dependencies
+	// and leaves the build dir alone
+	ds := newSolver(true)
+	if err = ds.resolveAURVersions([]target{toTarget("foo=1.1")}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if pkg := ds.AurCache["foo"]; pkg == nil || pkg.Version != "1.1" || !reflect.DeepEqual(pkg.Depends, []string{"qux"}) {
+		t.Fatalf("Unexpected package %+v", pkg)
+	}
+	if dirExists(filepath.Join(config.BuildDir, "foo")) {
+		t.Fatalf("Expected foo not to be cloned")
+	}
+
+	ds = newSolver(false)
+	if err = ds.resolveAURVersions([]target{toTarget("foo==1.1-1")}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	rev, ok := ds.Revisions["foo"]
+	if !ok || rev.Version != "1.1-1" || rev.Commit == "" {
+		t.Fatalf("Unexpected revision %+v", rev)
+	}
+	if pkg := ds.AurCache["foo"]; pkg == nil || pkg.Version != "1.1-1" || !reflect.DeepEqual(pkg.Depends, []string{"baz"}) {
+		t.Fatalf("Unexpected package %+v", pkg)
+	}
+
+	// The clone is searched as it is once it exists
+	ds = newSolver(true)
+	if err = ds.resolveAURVersions([]target{toTarget("foo=1.0")}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if pkg := ds.AurCache["foo"]; pkg == nil || !reflect.DeepEqual(pkg.Depends, []string{"bar"}) || ds.Revisions["foo"].Commit == "" {
+		t.Fatalf("Unexpected package %+v", pkg)
+	}
+
+	// The current version needs no history
+	ds = newSolver(false)
+	if err = ds.resolveAURVersions([]target{toTarget("foo=2.0-1")}); err != nil || len(ds.Revisions) != 0 {
+		t.Fatalf("Expected nothing to be resolved, got %v %v", ds.Revisions, err)
+	}
+
+	ds = newSolver(false)
+	if err = ds.resolveAURVersions([]target{toTarget("foo=0.5")}); err == nil {
+		t.Fatalf("Expected an error for a version never released")
+	}
+}
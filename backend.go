@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	gosrc "github.com/Morganamilo/go-srcinfo"
+	rpc "github.com/mikkeloscar/aur"
+)
+
+// aurBackend answers the package metadata queries yay would otherwise send to
+// the AUR RPC interface.
+type aurBackend interface {
+	// Info returns the packages matching names exactly.
+	Info(names []string) ([]rpc.Pkg, error)
+	// Search returns the packages whose name or description contain query.
+	Search(query string) ([]rpc.Pkg, error)
+	// Provides returns packages that may provide name. False positives are
+	// allowed, the results are checked again during dependency resolving.
+	Provides(name string) ([]rpc.Pkg, error)
+}
+
+// aurClient is the backend selected by config.AURURL.
+var aurClient aurBackend = rpcBackend{newRPCClient(rpc.AURURL, nil, "", 1)}
+
+// selectAURBackend picks the backend for the current config.AURURL. A file://
+// url selects a local tree of package bases, anything else uses the AUR RPC
+// with its results saved to the AUR cache. In offline mode only the cache and
+// the build dir are used.
+func selectAURBackend() {
+	if strings.HasPrefix(config.AURURL, "file://") {
+		aurClient = &localBackend{root: strings.TrimPrefix(config.AURURL, "file://")}
+	} else if config.Offline {
+		aurClient = &offlineBackend{cache: &aurCache{path: aurCacheFile}}
+	} else {
+		client := newRPCClient(rpc.AURURL, nil, filepath.Join(cacheHome, "rpc"), config.MaxConnections)
+		aurClient = cachingBackend{rpcBackend{client}, &aurCache{path: aurCacheFile}}
+	}
+}
+
+// aurGitURL returns the url used to clone pkgbase.
+func aurGitURL(pkgbase string) string {
+	if local, ok := aurClient.(*localBackend); ok {
+		path := filepath.Join(local.root, pkgbase)
+		if _, err := os.Stat(path); err != nil {
+			path += ".git"
+		}
+		return path
+	}
+
+	return config.AURURL + "/" + pkgbase + ".git"
+}
+
+// rpcBackend queries the AUR over http.
+type rpcBackend struct {
+	client *rpcClient
+}
+
+func (r rpcBackend) Info(names []string) ([]rpc.Pkg, error) {
+	return r.client.Info(names)
+}
+
+func (r rpcBackend) Search(query string) ([]rpc.Pkg, error) {
+	return r.client.Search(query)
+}
+
+// Provides performs a search of the package name as the RPC has no way to
+// search by provides.
+func (r rpcBackend) Provides(name string) ([]rpc.Pkg, error) {
+	var results []rpc.Pkg
+	var err error
+
+	// Hack for a bigger search result, if the user wants
+	// java-envronment we can search for just java instead and get
+	// more hits.
+	words := strings.Split(name, "-")
+
+	for i := range words {
+		results, err = r.client.SearchByNameDesc(strings.Join(words[:i+1], "-"))
+		if err == nil {
+			break
+		}
+	}
+
+	return results, err
+}
+
+// localBackend answers queries from a directory holding one directory per
+// package base, each containing a .SRCINFO. The directories are usually git
+// repos cloned from the AUR so they can also be built from.
+type localBackend struct {
+	root string
+
+	once sync.Once
+	pkgs []rpc.Pkg
+	err  error
+}
+
+func (l *localBackend) load() ([]rpc.Pkg, error) {
+	l.once.Do(func() {
+		l.pkgs, l.err = readSrcinfoTree(l.root, localArch())
+	})
+
+	return l.pkgs, l.err
+}
+
+func (l *localBackend) Info(names []string) ([]rpc.Pkg, error) {
+	pkgs, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := sliceToStringSet(names)
+	info := make([]rpc.Pkg, 0, len(names))
+	for _, pkg := range pkgs {
+		if wanted.get(pkg.Name) {
+			info = append(info, pkg)
+		}
+	}
+
+	return info, nil
+}
+
+func (l *localBackend) Search(query string) ([]rpc.Pkg, error) {
+	pkgs, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return searchPkgs(pkgs, query), nil
+}
+
+func (l *localBackend) Provides(name string) ([]rpc.Pkg, error) {
+	pkgs, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return providesPkgs(pkgs, name), nil
+}
+
+// searchPkgs returns the packages whose name or description contain query.
+func searchPkgs(pkgs []rpc.Pkg, query string) []rpc.Pkg {
+	query = strings.ToLower(query)
+	results := make([]rpc.Pkg, 0)
+	for _, pkg := range pkgs {
+		if strings.Contains(pkg.Name, query) || strings.Contains(strings.ToLower(pkg.Description), query) {
+			results = append(results, pkg)
+		}
+	}
+
+	return results
+}
+
+// providesPkgs returns the packages named name or providing it.
+func providesPkgs(pkgs []rpc.Pkg, name string) []rpc.Pkg {
+	results := make([]rpc.Pkg, 0)
+	for _, pkg := range pkgs {
+		if pkg.Name == name {
+			results = append(results, pkg)
+			continue
+		}
+
+		for _, provide := range pkg.Provides {
+			if provideName, _, _ := splitDep(provide); provideName == name {
+				results = append(results, pkg)
+				break
+			}
+		}
+	}
+
+	return results
+}
+
+// localArch returns the architecture used to pick arch specific fields, or ""
+// to accept all of them.
+func localArch() string {
+	if alpmHandle == nil {
+		return ""
+	}
+
+	arch, err := alpmHandle.Arch()
+	if err != nil {
+		return ""
+	}
+
+	return arch
+}
+
+// readSrcinfoTree parses every root/*/.SRCINFO into the packages the RPC
+// would return for it. Directories without a .SRCINFO are ignored.
+func readSrcinfoTree(root string, arch string) ([]rpc.Pkg, error) {
+	dirs, err := filepath.Glob(filepath.Join(root, "*", ".SRCINFO"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(dirs)
+	pkgs := make([]rpc.Pkg, 0, len(dirs))
+
+	for _, path := range dirs {
+		srcinfo, err := gosrc.ParseFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var modified int
+		if stat, err := os.Stat(path); err == nil {
+			modified = int(stat.ModTime().Unix())
+		}
+
+		maintainer := pkgbuildMaintainer(filepath.Join(filepath.Dir(path), "PKGBUILD"))
+
+		for _, pkg := range srcinfoPkgs(srcinfo, arch) {
+			pkg.Maintainer = maintainer
+			pkg.LastModified = modified
+			pkgs = append(pkgs, pkg)
+		}
+	}
+
+	return pkgs, nil
+}
+
+// srcinfoPkgs converts each package of a .SRCINFO into the package the RPC
+// would return for it.
+func srcinfoPkgs(srcinfo *gosrc.Srcinfo, arch string) []rpc.Pkg {
+	pkgs := make([]rpc.Pkg, 0, len(srcinfo.Packages))
+
+	for _, split := range srcinfo.SplitPackages() {
+		pkgs = append(pkgs, rpc.Pkg{
+			Name:         split.Pkgname,
+			PackageBase:  srcinfo.Pkgbase,
+			Version:      srcinfo.Version(),
+			Description:  split.Pkgdesc,
+			URL:          split.URL,
+			URLPath:      "/" + srcinfo.Pkgbase,
+			Depends:      archValues(split.Depends, arch),
+			MakeDepends:  archValues(srcinfo.MakeDepends, arch),
+			CheckDepends: archValues(srcinfo.CheckDepends, arch),
+			Conflicts:    archValues(split.Conflicts, arch),
+			Provides:     archValues(split.Provides, arch),
+			Replaces:     archValues(split.Replaces, arch),
+			OptDepends:   archValues(split.OptDepends, arch),
+			Groups:       split.Groups,
+			License:      split.License,
+		})
+	}
+
+	return pkgs
+}
+
+// archValues flattens values keeping the ones that apply to arch.
+func archValues(values []gosrc.ArchString, arch string) []string {
+	flat := make([]string, 0, len(values))
+	for _, value := range values {
+		if value.Arch == "" || arch == "" || value.Arch == arch {
+			flat = append(flat, value.Value)
+		}
+	}
+
+	return flat
+}
+
+// pkgbuildMaintainer reads the name from the first "# Maintainer:" comment of
+// a PKGBUILD.
+func pkgbuildMaintainer(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	return parseMaintainer(file)
+}
+
+func parseMaintainer(r io.Reader) string {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		if strings.HasPrefix(line, "Maintainer:") {
+			maintainer := strings.TrimSpace(strings.TrimPrefix(line, "Maintainer:"))
+			if i := strings.Index(maintainer, "<"); i != -1 {
+				maintainer = strings.TrimSpace(maintainer[:i])
+			}
+			return maintainer
+		}
+	}
+
+	return ""
+}
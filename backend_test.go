@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gosrc "github.com/Morganamilo/go-srcinfo"
+)
+
+const testSrcinfo = `pkgbase = foo
+	pkgdesc = Frobnicates the widgets
+	pkgver = 1.0
+	pkgrel = 2
+	arch = x86_64
+	arch = i686
+	makedepends = make
+	depends = glibc
+	depends_x86_64 = lib64
+	depends_i686 = lib32
+
+pkgname = foo
+	provides = libfoo=1.0
+
+pkgname = foo-docs
+	pkgdesc = Documentation for foo
+`
+
+func TestLocalBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yay-backend")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(filepath.Join(dir, "foo"), 0755)
+	os.MkdirAll(filepath.Join(dir, "empty"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "foo", ".SRCINFO"), []byte(testSrcinfo), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "foo", "PKGBUILD"), []byte("# Maintainer: Jane Doe <jane@example.org>\npkgname=foo\n"), 0644)
+
+	pkgs, err := readSrcinfoTree(dir, "x86_64")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(pkgs) != 2 {
+		t.Fatalf("Expected 2 packages got %d", len(pkgs))
+	}
+
+	foo := pkgs[0]
+	if foo.Name != "foo" || foo.PackageBase != "foo" || foo.Version != "1.0-2" || foo.Maintainer != "Jane Doe" {
+		t.Fatalf("Unexpected package %+v", foo)
+	}
+
+	if len(foo.Depends) != 2 || foo.Depends[0] != "glibc" || foo.Depends[1] != "lib64" {
+		t.Fatalf("Expected depends [glibc lib64] got %v", foo.Depends)
+	}
+
+	if pkgs[1].Description != "Documentation for foo" || len(pkgs[1].MakeDepends) != 1 {
+		t.Fatalf("Unexpected package %+v", pkgs[1])
+	}
+
+	local := &localBackend{root: dir, pkgs: pkgs}
+	local.once.Do(func() {})
+
+	info, _ := local.Info([]string{"foo-docs", "bar"})
+	if len(info) != 1 || info[0].Name != "foo-docs" {
+		t.Fatalf("Expected info for foo-docs got %v", info)
+	}
+
+	results, _ := local.Search("WIDGETS")
+	if len(results) != 1 || results[0].Name != "foo" {
+		t.Fatalf("Expected search to find foo got %v", results)
+	}
+
+	results, _ = local.Provides("libfoo")
+	if len(results) != 1 || results[0].Name != "foo" {
+		t.Fatalf("Expected foo to provide libfoo got %v", results)
+	}
+}
+
+func TestSrcinfoPkgs(t *testing.T) {
+	srcinfo, err := gosrc.Parse(testSrcinfo)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	testCases := []struct {
+		arch    string
+		depends []string
+	}{
+		{"x86_64", []string{"glibc", "lib64"}},
+		{"i686", []string{"glibc", "lib32"}},
+		{"", []string{"glibc", "lib64", "lib32"}},
+	}
+
+	for _, tc := range testCases {
+		pkgs := srcinfoPkgs(srcinfo, tc.arch)
+		if len(pkgs) != 2 || pkgs[0].Name != "foo" || pkgs[1].Name != "foo-docs" {
+			t.Fatalf("%s: unexpected packages %v", tc.arch, pkgs)
+		}
+
+		foo := pkgs[0]
+		if foo.PackageBase != "foo" || foo.Version != "1.0-2" || foo.URLPath != "/foo" {
+			t.Fatalf("%s: unexpected package %+v", tc.arch, foo)
+		}
+		if !reflect.DeepEqual(foo.Depends, tc.depends) {
+			t.Fatalf("%s: expected depends %v got %v", tc.arch, tc.depends, foo.Depends)
+		}
+		if !reflect.DeepEqual(foo.Provides, []string{"libfoo=1.0"}) || !reflect.DeepEqual(foo.MakeDepends, []string{"make"}) {
+			t.Fatalf("%s: unexpected package %+v", tc.arch, foo)
+		}
+		if pkgs[1].Description != "Documentation for foo" || len(pkgs[1].Provides) != 0 {
+			t.Fatalf("%s: unexpected package %+v", tc.arch, pkgs[1])
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// buildResult is sent back to the scheduler once a worker has finished
+// building a base.
+type buildResult struct {
+	index    int
+	pkgdests map[string]string
+	version  string
+	skip     bool
+	err      error
+}
+
+// baseDependencies maps the index of each base in bases to the indexes of the
+// other bases it needs installed before it can be built.
+// Only depends, makedepends and checkdepends are considered as these are the
+// only deps makepkg needs while building.
+func baseDependencies(bases []Base) map[int][]int {
+	deps := make(map[int][]int)
+
+	for i, base := range bases {
+		seen := make(map[int]bool)
+
+		for _, pkg := range base {
+			for _, depList := range [3][]string{pkg.Depends, pkg.MakeDepends, pkg.CheckDepends} {
+				for _, dep := range depList {
+					for j, other := range bases {
+						if i == j || seen[j] {
+							continue
+						}
+
+						for _, otherPkg := range other {
+							if satisfiesAur(dep, otherPkg) {
+								seen[j] = true
+								deps[i] = append(deps[i], j)
+								break
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return deps
+}
+
+// nextBuildable returns the index of the first pending base in bases whose
+// dependencies have all been installed, or -1 if no base is ready.
+func nextBuildable(pending []int, deps map[int][]int, installed map[int]bool) int {
+	for n, i := range pending {
+		ready := true
+		for _, dep := range deps[i] {
+			if !installed[dep] {
+				ready = false
+				break
+			}
+		}
+
+		if ready {
+			return n
+		}
+	}
+
+	return -1
+}
+
+// scheduleBuilds builds every base in bases using up to jobs concurrent
+// workers. A base is only handed to a worker once every base it depends on
+// has been installed. Installs are always performed one at a time on the
+// calling goroutine in the order builds finish.
+func scheduleBuilds(bases []Base, jobs int, build func(int, io.Writer) buildResult, install func(buildResult) error) error {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	deps := baseDependencies(bases)
+	pending := make([]int, 0, len(bases))
+	installed := make(map[int]bool)
+	results := make(chan buildResult, len(bases))
+	running := 0
+	var err error
+
+	for i := range bases {
+		pending = append(pending, i)
+	}
+
+	start := func(n int) {
+		i := pending[n]
+		pending = append(pending[:n], pending[n+1:]...)
+		running++
+
+		go func() {
+			if jobs == 1 {
+				results <- build(i, os.Stdout)
+				return
+			}
+
+			out := newPrefixWriter(os.Stdout, bases[i].Pkgbase())
+			result := build(i, out)
+			out.Flush()
+			results <- result
+		}()
+	}
+
+	for len(pending) > 0 || running > 0 {
+		for err == nil && running < jobs {
+			n := nextBuildable(pending, deps, installed)
+			if n == -1 {
+				// Nothing is ready and nothing is running. This only
+				// happens when there is a dependency cycle so fall back to
+				// the order the bases were given in.
+				if running == 0 && len(pending) > 0 {
+					n = 0
+				} else {
+					break
+				}
+			}
+
+			start(n)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		result := <-results
+		running--
+
+		if err != nil {
+			continue
+		}
+
+		if result.err != nil {
+			err = result.err
+			continue
+		}
+
+		if err = install(result); err != nil {
+			continue
+		}
+
+		installed[result.index] = true
+	}
+
+	return err
+}
+
+// printMu serialises writes to stdout from concurrent builds.
+var printMu sync.Mutex
+
+// prefixWriter labels each line written to it with the name of the base that
+// produced it so output from concurrent builds can be told apart.
+type prefixWriter struct {
+	out    io.Writer
+	prefix string
+	buf    bytes.Buffer
+}
+
+func newPrefixWriter(out io.Writer, name string) *prefixWriter {
+	return &prefixWriter{out: out, prefix: bold(cyan(name)) + " " + bold(magenta("|")) + " "}
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+
+	for {
+		line, err := w.buf.ReadBytes('\n')
+		if err != nil {
+			// incomplete line, wait for the rest
+			w.buf.Reset()
+			w.buf.Write(line)
+			break
+		}
+
+		w.writeLine(line)
+	}
+
+	return len(p), nil
+}
+
+// Flush writes out any trailing output that did not end with a newline.
+func (w *prefixWriter) Flush() {
+	if w.buf.Len() > 0 {
+		w.writeLine(append(w.buf.Bytes(), '\n'))
+		w.buf.Reset()
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	printMu.Lock()
+	fmt.Fprint(w.out, w.prefix)
+	w.out.Write(line)
+	printMu.Unlock()
+}
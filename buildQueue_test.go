@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	rpc "github.com/mikkeloscar/aur"
+)
+
+func makeTestBases() []Base {
+	return []Base{
+		{&rpc.Pkg{Name: "a", PackageBase: "a"}},
+		{&rpc.Pkg{Name: "b", PackageBase: "b", Depends: []string{"a"}}},
+		{&rpc.Pkg{Name: "c", PackageBase: "c"}},
+		{&rpc.Pkg{Name: "d1", PackageBase: "d", MakeDepends: []string{"b"}}, &rpc.Pkg{Name: "d2", PackageBase: "d", CheckDepends: []string{"prov"}}},
+		{&rpc.Pkg{Name: "e", PackageBase: "e", Provides: []string{"prov"}}},
+	}
+}
+
+func TestBaseDependencies(t *testing.T) {
+	deps := baseDependencies(makeTestBases())
+
+	expected := map[int][]int{
+		1: {0},
+		3: {1, 4},
+	}
+
+	if len(deps) != len(expected) {
+		t.Fatalf("Expected %v got %v", expected, deps)
+	}
+
+	for i, want := range expected {
+		if fmt.Sprint(deps[i]) != fmt.Sprint(want) {
+			t.Fatalf("Base %d: expected deps %v got %v", i, want, deps[i])
+		}
+	}
+}
+
+func TestScheduleBuilds(t *testing.T) {
+	bases := makeTestBases()
+	deps := baseDependencies(bases)
+
+	for _, jobs := range []int{1, 2, 8} {
+		var mux sync.Mutex
+		built := make(map[int]bool)
+		order := make([]int, 0, len(bases))
+
+		build := func(i int, out io.Writer) buildResult {
+			mux.Lock()
+			built[i] = true
+			mux.Unlock()
+			return buildResult{index: i}
+		}
+
+		install := func(result buildResult) error {
+			for _, dep := range deps[result.index] {
+				found := false
+				for _, n := range order {
+					found = found || n == dep
+				}
+
+				if !found {
+					t.Fatalf("jobs=%d: %d installed before its dependency %d", jobs, result.index, dep)
+				}
+			}
+
+			order = append(order, result.index)
+			return nil
+		}
+
+		if err := scheduleBuilds(bases, jobs, build, install); err != nil {
+			t.Fatalf("jobs=%d: unexpected error: %s", jobs, err)
+		}
+
+		if len(order) != len(bases) || len(built) != len(bases) {
+			t.Fatalf("jobs=%d: expected %d installs got %v", jobs, len(bases), order)
+		}
+	}
+}
+
+func TestScheduleBuildsError(t *testing.T) {
+	bases := makeTestBases()
+	var mux sync.Mutex
+	built := make(map[int]bool)
+
+	build := func(i int, out io.Writer) buildResult {
+		mux.Lock()
+		built[i] = true
+		mux.Unlock()
+
+		if bases[i].Pkgbase() == "a" {
+			return buildResult{index: i, err: fmt.Errorf("Error making: a")}
+		}
+		return buildResult{index: i}
+	}
+
+	install := func(result buildResult) error {
+		return nil
+	}
+
+	err := scheduleBuilds(bases, 2, build, install)
+	if err == nil || err.Error() != "Error making: a" {
+		t.Fatalf("Expected build error got %v", err)
+	}
+
+	if built[1] || built[3] {
+		t.Fatalf("Bases depending on a failed build should not be built: %v", built)
+	}
+}
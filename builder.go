@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// builder runs the build of a single base once its sources are ready.
+type builder interface {
+	// prepare is called once before any base is built.
+	prepare() error
+	// build builds the base in dir. deps holds the package files of
+	// previously built AUR packages the base depends on.
+	build(dir string, ignoreArch bool, deps []string, out io.Writer) error
+}
+
+func getBuilder() builder {
+	if config.Chroot {
+		return &chrootBuilder{root: filepath.Join(config.BuildDir, ".chroot")}
+	}
+
+	return hostBuilder{}
+}
+
+// hostBuilder builds with makepkg directly on the host. AUR dependencies have
+// already been installed on the host so deps is not needed.
+type hostBuilder struct{}
+
+func (hostBuilder) prepare() error {
+	return nil
+}
+
+func (hostBuilder) build(dir string, ignoreArch bool, deps []string, out io.Writer) error {
+	args := []string{"-cf", "--noconfirm", "--noextract", "--noprepare", "--holdver"}
+
+	if ignoreArch {
+		args = append(args, "--ignorearch")
+	}
+
+	return showOutput(passToMakepkg(dir, args...), out)
+}
+
+// chrootBuilder builds in a clean chroot using devtools. The chroot is created
+// with mkarchroot under root and every build runs in a fresh copy of it made
+// by makechrootpkg.
+type chrootBuilder struct {
+	root string
+}
+
+func (c *chrootBuilder) prepare() error {
+	master := filepath.Join(c.root, "root")
+
+	if _, err := os.Stat(master); os.IsNotExist(err) {
+		if err = os.MkdirAll(c.root, 0755); err != nil {
+			return err
+		}
+
+		args := []string{"mkarchroot"}
+		if config.PacmanConf != "" {
+			args = append(args, "-C", config.PacmanConf)
+		}
+		if config.MakepkgConf != "" {
+			args = append(args, "-M", config.MakepkgConf)
+		}
+		args = append(args, master, "base-devel")
+
+		if err = show(exec.Command("sudo", args...)); err != nil {
+			return fmt.Errorf("Error creating chroot: %s", master)
+		}
+
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	err := show(exec.Command("sudo", "arch-nspawn", master, "pacman", "-Syu", "--noconfirm"))
+	if err != nil {
+		return fmt.Errorf("Error updating chroot: %s", master)
+	}
+
+	return nil
+}
+
+// checkChrootOverrides refuses overrides that can not be applied to builds in
+// a chroot. makechrootpkg uses the chroot's makepkg.conf and does not pass
+// the environment on to makepkg, so only the extra flags work there.
+func checkChrootOverrides(bases []Base) error {
+	for _, base := range bases {
+		override := config.Overrides[base.Pkgbase()]
+		if override.MakepkgConf != "" || len(override.Env) > 0 {
+			return fmt.Errorf("%s: makepkgconf and env overrides can not be used with --chroot", base.Pkgbase())
+		}
+	}
+
+	return nil
+}
+
+func (c *chrootBuilder) build(dir string, ignoreArch bool, deps []string, out io.Writer) error {
+	// Each base gets its own working copy of the chroot so that builds
+	// running at the same time do not share one.
+	args := []string{"-c", "-r", c.root, "-l", filepath.Base(dir)}
+	for _, dep := range deps {
+		args = append(args, "-I", dep)
+	}
+
+	// The cached sources are links into the cache so it has to be visible
+	// inside the chroot at the same path.
+	srcdest := srcCacheDir(filepath.Base(dir))
+	if srcdest != "" {
+		args = append(args, "-D", filepath.Join(config.SrcDest, "sources"))
+	}
+
+	args = append(args, "--", "--holdver")
+	if ignoreArch {
+		args = append(args, "--ignorearch")
+	}
+	args = append(args, strings.Fields(config.MFlags)...)
+	args = append(args, strings.Fields(config.Overrides[filepath.Base(dir)].MFlags)...)
+
+	cmd := exec.Command("makechrootpkg", args...)
+	cmd.Dir = dir
+	if srcdest != "" {
+		cmd.Env = append(os.Environ(), "SRCDEST="+srcdest)
+	}
+	return showOutput(cmd, out)
+}
@@ -92,10 +92,16 @@ func questionCallback(question alpm.QuestionAny) {
 }
 
 func logCallback(level alpm.LogLevel, str string) {
+	// Keep stdout to the JSON alone when it is being printed
+	out := os.Stdout
+	if cmdArgs.existsArg("json") {
+		out = os.Stderr
+	}
+
 	switch level {
 	case alpm.LogWarning:
-		fmt.Print(bold(yellow(smallArrow)), " ", str)
+		fmt.Fprint(out, bold(yellow(smallArrow)), " ", str)
 	case alpm.LogError:
-		fmt.Print(bold(red(smallArrow)), " ", str)
+		fmt.Fprint(out, bold(red(smallArrow)), " ", str)
 	}
 }
@@ -90,7 +90,11 @@ func syncClean(parser *arguments) error {
 		err = cleanAUR(keepInstalled, keepCurrent, removeAll)
 	}
 
-	if err != nil || removeAll {
+	if err != nil {
+		return err
+	}
+
+	if err = cleanSrcCache(parser, removeAll); err != nil || removeAll {
 		return err
 	}
 
@@ -154,6 +158,11 @@ func cleanAUR(keepInstalled, keepCurrent, removeAll bool) error {
 			continue
 		}
 
+		// The source cache may live in the build directory
+		if config.SrcDest != "" && filepath.Join(config.BuildDir, file.Name()) == filepath.Clean(config.SrcDest) {
+			continue
+		}
+
 		if !removeAll {
 			if keepInstalled && installedBases.get(file.Name()) {
 				continue
@@ -35,12 +35,23 @@ New operations:
 New options:
        --repo             Assume targets are from the repositories
     -a --aur              Assume targets are from the AUR
+       --plan             Print the install plan for -S and -Su and exit
+       --resume           Continue the last install that failed to build
+       --skipfailed       With --resume skip the failed package and the
+                          packages depending on it
+       --offline          Use cached AUR data instead of querying the AUR
+       --srcmaxage  <days> With -Sc remove cached sources unused for days
+       --srcmaxsize <MiB>  With -Sc remove the least recently used cached
+                          sources until the cache fits
+       --whydepends       With -Q show which explicitly installed packages
+                          keep a package installed
 
 Permanent configuration options:
     --save                Causes the following options to be saved back to the
                           config file when used
 
     --aururl      <url>   Set an alternative AUR URL
+    --absurl      <url>   URL template used to download repo PKGBUILDs
     --builddir    <dir>   Directory used to download and run PKBUILDS
     --editor      <file>  Editor to use when editing PKGBUILDs
     --editorflags <flags> Pass arguments to editor
@@ -57,6 +68,19 @@ Permanent configuration options:
     --nomakepkgconf       Use the default makepkg.conf
 
     --requestsplitn <n>   Max amount of packages to query per AUR request
+    --buildjobs     <n>   Max amount of AUR packages to build at once
+    --downloadjobs  <n>   Max amount of AUR packages to download sources for at once
+    --httptimeout   <n>   Time in seconds before HTTP requests time out
+    --httpproxy   <url>   Proxy to use for HTTP requests
+    --cafile     <file>   Extra CA certificates to trust
+    --useragent <agent>   User-Agent to send with HTTP requests
+    --maxconnections <n>  Max amount of connections to a single host
+    --localrepo  <name>   Publish built AUR packages to a local repo
+    --nolocalrepo         Install built AUR packages directly
+    --srcdest     <dir>   Keep downloaded sources in a shared cache
+    --nosrcdest           Keep downloaded sources in each build directory
+    --reviewcmds <cmds>   Colon separated commands to review PKGBUILDs with
+    --noreviewcmds        Do not run PKGBUILD review commands
     --completioninterval  <n> Time in days to to refresh completion cache
     --sortby    <field>   Sort AUR results by a specific field during search
     --answerclean   <a>   Set a predetermined answer for the clean build menu
@@ -81,6 +105,8 @@ Permanent configuration options:
 
     --cleanafter          Remove package sources after successful install
     --nocleanafter        Do not remove package sources after successful build
+    --chroot              Build AUR packages in a clean chroot
+    --nochroot            Build AUR packages on the host
     --bottomup            Shows AUR's packages first and then repository's
     --topdown             Shows repository's packages first and then AUR's
 
@@ -114,15 +140,33 @@ show specific options:
     -c --complete         Used for completions
     -d --defaultconfig    Print default yay configuration
     -g --currentconfig    Print current yay configuration
+       --json             Print upgrades as JSON, also applies to -Qu
     -s --stats            Display system package statistics
+       --tree             Print the dependency tree of packages
+       --dot              Print the dependency tree in Graphviz DOT format
+       --rdeps            Show which explicitly installed packages keep a
+                          package installed
     -w --news             Print arch news
 
 yay specific options:
     -c --clean            Remove unneeded dependencies
        --gendb            Generates development package DB used for updating
+       --history          List previous AUR transactions
+       --rollback   <id>  Reinstall the packages from an AUR transaction
+       --sync-manifest <file>
+                          Install and remove packages to match a manifest
+       --removeextras     Remove packages not in the manifest instead of
+                          marking them as dependencies
+       --dryrun           Print what --sync-manifest would do and exit
+       --pin              Hold AUR packages at their version or a constraint,
+                          list pins without targets
+       --unpin            Remove the pins of AUR packages
+       --reason   <text>  With --pin record why a package is pinned
 
 getpkgbuild specific options:
     -f --force            Force download for existing tar packages
+       --log              List the versions of AUR packages and their commits
+       --commit   <sha>   Check out an AUR package at a commit
 
 If no arguments are provided 'yay -Syu' will be performed
 If no operation is provided -Y will be assumed`)
@@ -174,6 +218,9 @@ func handleQuery() error {
 	if cmdArgs.existsArg("u", "upgrades") {
 		return printUpdateList(cmdArgs)
 	}
+	if cmdArgs.existsArg("whydepends") {
+		return printWhyDepends(cmdArgs.targets)
+	}
 	return show(passToPacman(cmdArgs))
 }
 
@@ -198,7 +245,7 @@ func handlePrint() (err error) {
 	case cmdArgs.existsArg("g", "currentconfig"):
 		fmt.Printf("%v", config)
 	case cmdArgs.existsArg("n", "numberupgrades"):
-		err = printNumberOfUpdates()
+		err = printNumberOfUpdates(cmdArgs)
 	case cmdArgs.existsArg("u", "upgrades"):
 		err = printUpdateList(cmdArgs)
 	case cmdArgs.existsArg("w", "news"):
@@ -209,6 +256,10 @@ func handlePrint() (err error) {
 		complete(false)
 	case cmdArgs.existsArg("s", "stats"):
 		err = localStatistics()
+	case cmdArgs.existsArg("tree"):
+		err = printDepTree(cmdArgs.targets)
+	case cmdArgs.existsArg("rdeps"):
+		err = printWhyDepends(cmdArgs.targets)
 	default:
 		err = nil
 	}
@@ -220,6 +271,28 @@ func handleYay() error {
 	if cmdArgs.existsArg("gendb") {
 		return createDevelDB()
 	}
+	if cmdArgs.existsArg("history") {
+		return printHistory()
+	}
+	if value, _, exists := cmdArgs.getArg("rollback"); exists {
+		return rollback(value)
+	}
+	if cmdArgs.existsArg("resume") {
+		return resumeInstall()
+	}
+	if value, _, exists := cmdArgs.getArg("sync-manifest"); exists {
+		return syncManifest(value)
+	}
+	if cmdArgs.existsArg("pin") {
+		if len(cmdArgs.targets) == 0 {
+			return printPins()
+		}
+		reason, _, _ := cmdArgs.getArg("reason")
+		return pinPackages(cmdArgs.targets, reason)
+	}
+	if cmdArgs.existsArg("unpin") {
+		return unpinPackages(cmdArgs.targets)
+	}
 	if cmdArgs.existsDouble("c") {
 		return cleanDependencies(true)
 	}
@@ -252,6 +325,14 @@ func handleSync() error {
 		}
 		return syncSearch(targets)
 	}
+	if cmdArgs.existsArg("resume") {
+		return resumeInstall()
+	}
+	// A sysupgrade may include AUR upgrades, so it is planned too
+	aurPrint := hasAURTargets(targets) || (cmdArgs.existsArg("u", "sysupgrade") && mode != modeRepo)
+	if cmdArgs.existsArg("plan") || (cmdArgs.existsArg("p", "print") && !cmdArgs.existsArg("print-format") && aurPrint) {
+		return install(cmdArgs)
+	}
 	if cmdArgs.existsArg("p", "print", "print-format") {
 		return show(passToPacman(cmdArgs))
 	}
@@ -279,6 +360,43 @@ func handleSync() error {
 	return nil
 }
 
+// hasAURTargets reports whether any of targets would come from the AUR, that
+// is it has the aur/ prefix or is neither a repo package nor a group.
+func hasAURTargets(targets []string) bool {
+	if mode == modeRepo {
+		return false
+	}
+	if mode == modeAUR {
+		return len(targets) > 0
+	}
+
+	syncDb, err := alpmHandle.SyncDbs()
+	if err != nil {
+		return false
+	}
+
+	for _, pkg := range targets {
+		target := toTarget(pkg)
+		if target.Db == "aur" {
+			return true
+		}
+		if target.Db != "" {
+			continue
+		}
+
+		if _, err := syncDb.FindSatisfier(target.DepString()); err == nil {
+			continue
+		}
+		if _, err := syncDb.PkgCachebyGroup(target.Name); err == nil {
+			continue
+		}
+
+		return true
+	}
+
+	return false
+}
+
 func handleRemove() error {
 	removeVCSPackage(cmdArgs.targets)
 	return show(passToPacman(cmdArgs))
@@ -3,7 +3,6 @@ package main
 import (
 	"bufio"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 	"time"
@@ -13,7 +12,21 @@ import (
 
 //CreateAURList creates a new completion file
 func createAURList(out *os.File) (err error) {
-	resp, err := http.Get(config.AURURL + "/packages.gz")
+	if local, ok := aurClient.(*localBackend); ok {
+		pkgs, err := local.load()
+		if err != nil {
+			return err
+		}
+
+		for _, pkg := range pkgs {
+			out.WriteString(pkg.Name)
+			out.WriteString("\tAUR\n")
+		}
+
+		return nil
+	}
+
+	resp, err := httpClient.Get(config.AURURL + "/packages.gz")
 	if err != nil {
 		return err
 	}
@@ -37,6 +37,7 @@ type targetMode int
 // Configuration stores yay's config.
 type Configuration struct {
 	AURURL             string `json:"aururl"`
+	ABSURL             string `json:"absurl"`
 	BuildDir           string `json:"buildDir"`
 	Editor             string `json:"editor"`
 	EditorFlags        string `json:"editorflags"`
@@ -58,15 +59,27 @@ type Configuration struct {
 	SortBy             string `json:"sortby"`
 	GitFlags           string `json:"gitflags"`
 	RemoveMake         string `json:"removemake"`
+	HTTPProxy          string `json:"httpproxy"`
+	CAFile             string `json:"cafile"`
+	UserAgent          string `json:"useragent"`
 	RequestSplitN      int    `json:"requestsplitn"`
+	HTTPTimeout        int    `json:"httptimeout"`
+	MaxConnections     int    `json:"maxconnections"`
+	BuildJobs          int    `json:"buildjobs"`
+	DownloadJobs       int    `json:"downloadjobs"`
 	SearchMode         int    `json:"-"`
 	SortMode           int    `json:"sortmode"`
 	CompletionInterval int    `json:"completionrefreshtime"`
 	SudoLoop           bool   `json:"sudoloop"`
 	TimeUpdate         bool   `json:"timeupdate"`
 	NoConfirm          bool   `json:"-"`
+	Offline            bool   `json:"-"`
 	Devel              bool   `json:"devel"`
 	CleanAfter         bool   `json:"cleanAfter"`
+	Chroot             bool   `json:"chroot"`
+	LocalRepo          string `json:"localrepo"`
+	SrcDest            string `json:"srcdest"`
+	ReviewCmds         string `json:"reviewcmds"`
 	GitClone           bool   `json:"gitclone"`
 	Provides           bool   `json:"provides"`
 	PGPFetch           bool   `json:"pgpfetch"`
@@ -76,6 +89,16 @@ type Configuration struct {
 	EditMenu           bool   `json:"editmenu"`
 	CombinedUpgrade    bool   `json:"combinedupgrade"`
 	UseAsk             bool   `json:"useask"`
+
+	Overrides map[string]pkgOverride `json:"overrides"`
+}
+
+// pkgOverride holds extra makepkg settings used when building a single
+// pkgbase.
+type pkgOverride struct {
+	MFlags      string            `json:"mflags"`
+	MakepkgConf string            `json:"makepkgconf"`
+	Env         map[string]string `json:"env"`
 }
 
 var version = "8.2.0"
@@ -86,6 +109,18 @@ const configFileName string = "config.json"
 // vcsFileName holds the name of the vcs file.
 const vcsFileName string = "vcs.json"
 
+// historyFileName holds the name of the transaction history file.
+const historyFileName string = "history.json"
+
+// sessionFileName holds the name of the file used to resume installs.
+const sessionFileName string = "session.json"
+
+// aurCacheFileName holds the name of the AUR metadata cache.
+const aurCacheFileName string = "aur.json"
+
+// pinsFileName holds the name of the file listing pinned packages.
+const pinsFileName string = "pins.json"
+
 // useColor enables/disables colored printing
 var useColor bool
 
@@ -104,6 +139,21 @@ var configFile string
 // vcsfile holds yay vcs info file path.
 var vcsFile string
 
+// historyFile holds yay transaction history file path.
+var historyFile string
+
+// sessionFile holds the path of the install to resume.
+var sessionFile string
+
+// aurCacheFile holds the path of the AUR metadata cache.
+var aurCacheFile string
+
+// pinsFile holds the path of the pinned packages file.
+var pinsFile string
+
+// patchesDir holds the directory of local PKGBUILD patches.
+var patchesDir string
+
 // shouldSaveConfig holds whether or not the config should be saved
 var shouldSaveConfig bool
 
@@ -140,8 +190,13 @@ func (config *Configuration) saveConfig() error {
 func defaultSettings() *Configuration {
 	config := &Configuration{
 		AURURL:             "https://aur.archlinux.org",
+		ABSURL:             "https://gitlab.archlinux.org/archlinux/packaging/packages/{pkgbase}.git",
 		BuildDir:           "$HOME/.cache/yay",
 		CleanAfter:         false,
+		Chroot:             false,
+		LocalRepo:          "",
+		SrcDest:            "",
+		ReviewCmds:         "",
 		Editor:             "",
 		EditorFlags:        "",
 		Devel:              false,
@@ -153,6 +208,7 @@ func defaultSettings() *Configuration {
 		PacmanConf:         "/etc/pacman.conf",
 		GpgFlags:           "",
 		MFlags:             "",
+		Overrides:          make(map[string]pkgOverride),
 		GitFlags:           "",
 		SortMode:           bottomUp,
 		CompletionInterval: 7,
@@ -162,7 +218,14 @@ func defaultSettings() *Configuration {
 		GitBin:             "git",
 		GpgBin:             "gpg",
 		TimeUpdate:         false,
+		HTTPProxy:          "",
+		CAFile:             "",
+		UserAgent:          "",
 		RequestSplitN:      150,
+		HTTPTimeout:        30,
+		MaxConnections:     4,
+		BuildJobs:          1,
+		DownloadJobs:       4,
 		ReDownload:         "no",
 		ReBuild:            "no",
 		AnswerClean:        "",
@@ -189,11 +252,20 @@ func defaultSettings() *Configuration {
 
 func (config *Configuration) expandEnv() {
 	config.AURURL = os.ExpandEnv(config.AURURL)
+	config.ABSURL = os.ExpandEnv(config.ABSURL)
 	config.BuildDir = os.ExpandEnv(config.BuildDir)
 	config.Editor = os.ExpandEnv(config.Editor)
 	config.EditorFlags = os.ExpandEnv(config.EditorFlags)
+	config.ReviewCmds = os.ExpandEnv(config.ReviewCmds)
+	config.CAFile = os.ExpandEnv(config.CAFile)
+	config.SrcDest = os.ExpandEnv(config.SrcDest)
 	config.MakepkgBin = os.ExpandEnv(config.MakepkgBin)
 	config.MakepkgConf = os.ExpandEnv(config.MakepkgConf)
+	for base, override := range config.Overrides {
+		override.MFlags = os.ExpandEnv(override.MFlags)
+		override.MakepkgConf = os.ExpandEnv(override.MakepkgConf)
+		config.Overrides[base] = override
+	}
 	config.PacmanBin = os.ExpandEnv(config.PacmanBin)
 	config.PacmanConf = os.ExpandEnv(config.PacmanConf)
 	config.GpgFlags = os.ExpandEnv(config.GpgFlags)
@@ -351,19 +423,86 @@ func toUsage(usages []string) alpm.Usage {
 	return ret
 }
 
+// defaultSigLevel is the SigLevel pacman uses when pacman.conf does not set one.
+const defaultSigLevel = alpm.SigPackage | alpm.SigPackageOptional | alpm.SigDatabase | alpm.SigDatabaseOptional
+
+// toSigLevel applies SigLevel values from pacman.conf on top of base the same
+// way pacman does. Values may be prefixed with Package or Database to only
+// affect that half of the level.
+func toSigLevel(values []string, base alpm.SigLevel) (alpm.SigLevel, error) {
+	level := base
+
+	for _, value := range values {
+		pkg, db := true, true
+
+		opt := value
+		if strings.HasPrefix(opt, "Package") {
+			opt = strings.TrimPrefix(opt, "Package")
+			db = false
+		} else if strings.HasPrefix(opt, "Database") {
+			opt = strings.TrimPrefix(opt, "Database")
+			pkg = false
+		}
+
+		var sig, optional, trust alpm.SigLevel
+		if pkg {
+			sig |= alpm.SigPackage
+			optional |= alpm.SigPackageOptional
+			trust |= alpm.SigPackageMarginalOk | alpm.SigPackageUnknownOk
+		}
+		if db {
+			sig |= alpm.SigDatabase
+			optional |= alpm.SigDatabaseOptional
+			trust |= alpm.SigDatabaseMarginalOk | alpm.SigDatabaseUnknownOk
+		}
+
+		switch opt {
+		case "Never":
+			level &^= sig
+		case "Optional":
+			level |= sig | optional
+		case "Required":
+			level |= sig
+			level &^= optional
+		case "TrustedOnly":
+			level &^= trust
+		case "TrustAll":
+			level |= trust
+		default:
+			return 0, fmt.Errorf("invalid SigLevel value: %s", value)
+		}
+	}
+
+	return level, nil
+}
+
 func configureAlpm(conf *pacmanconf.Config) error {
 	var err error
 
-	// TODO: set SigLevel
-	//sigLevel := alpm.SigPackage | alpm.SigPackageOptional | alpm.SigDatabase | alpm.SigDatabaseOptional
-	//localFileSigLevel := alpm.SigUseDefault
-	//remoteFileSigLevel := alpm.SigUseDefault
+	sigLevel, err := toSigLevel(pacmanConf.SigLevel, defaultSigLevel)
+	if err != nil {
+		return fmt.Errorf("[options]: %s", err)
+	}
+
+	localFileSigLevel, err := toSigLevel(pacmanConf.LocalFileSigLevel, sigLevel)
+	if err != nil {
+		return fmt.Errorf("[options] LocalFileSigLevel: %s", err)
+	}
+
+	remoteFileSigLevel, err := toSigLevel(pacmanConf.RemoteFileSigLevel, sigLevel)
+	if err != nil {
+		return fmt.Errorf("[options] RemoteFileSigLevel: %s", err)
+	}
 
 	for _, repo := range pacmanConf.Repos {
-		// TODO: set SigLevel
-		db, err := alpmHandle.RegisterSyncDb(repo.Name, 0)
+		repoSigLevel, err := toSigLevel(repo.SigLevel, sigLevel)
 		if err != nil {
-			return err
+			return fmt.Errorf("[%s]: %s", repo.Name, err)
+		}
+
+		db, err := alpmHandle.RegisterSyncDb(repo.Name, repoSigLevel)
+		if err != nil {
+			return fmt.Errorf("failed to register database '%s': %s", repo.Name, err)
 		}
 
 		db.SetServers(repo.Servers)
@@ -410,7 +549,7 @@ func configureAlpm(conf *pacmanconf.Config) error {
 		return err
 	}
 
-	/*if err = alpmHandle.SetDefaultSigLevel(sigLevel); err != nil {
+	if err = alpmHandle.SetDefaultSigLevel(sigLevel); err != nil {
 		return err
 	}
 
@@ -420,7 +559,7 @@ func configureAlpm(conf *pacmanconf.Config) error {
 
 	if err = alpmHandle.SetRemoteFileSigLevel(remoteFileSigLevel); err != nil {
 		return err
-	}*/
+	}
 
 	if err = alpmHandle.SetDeltaRatio(pacmanConf.UseDelta); err != nil {
 		return err
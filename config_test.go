@@ -3,6 +3,8 @@ package main
 import (
 	"reflect"
 	"testing"
+
+	alpm "github.com/jguer/go-alpm"
 )
 
 func expect(t *testing.T, field string, a interface{}, b interface{}, err error) {
@@ -59,4 +61,42 @@ func TestConfig(t *testing.T) {
 
 	check, err := h.CheckSpace()
 	expect(t, "CheckSpace", true, check, err)
+
+	sigLevel, err := h.GetDefaultSigLevel()
+	expect(t, "SigLevel", alpm.SigPackage|alpm.SigDatabase|alpm.SigDatabaseOptional, sigLevel, err)
+
+	localFileSigLevel, err := h.GetLocalFileSigLevel()
+	expect(t, "LocalFileSigLevel", alpm.SigPackage|alpm.SigPackageOptional|alpm.SigDatabase|alpm.SigDatabaseOptional, localFileSigLevel, err)
+
+	remoteFileSigLevel, err := h.GetRemoteFileSigLevel()
+	expect(t, "RemoteFileSigLevel", alpm.SigPackage|alpm.SigDatabase|alpm.SigDatabaseOptional, remoteFileSigLevel, err)
+}
+
+func TestToSigLevel(t *testing.T) {
+	tests := []struct {
+		values   []string
+		base     alpm.SigLevel
+		expected alpm.SigLevel
+	}{
+		{nil, defaultSigLevel, defaultSigLevel},
+		{[]string{"Never"}, defaultSigLevel, alpm.SigPackageOptional | alpm.SigDatabaseOptional},
+		{[]string{"Required", "DatabaseOptional"}, defaultSigLevel, alpm.SigPackage | alpm.SigDatabase | alpm.SigDatabaseOptional},
+		{[]string{"PackageRequired", "PackageTrustedOnly"}, defaultSigLevel, alpm.SigPackage | alpm.SigDatabase | alpm.SigDatabaseOptional},
+		{[]string{"DatabaseNever", "TrustAll"}, alpm.SigPackage, alpm.SigPackage | alpm.SigPackageMarginalOk | alpm.SigPackageUnknownOk | alpm.SigDatabaseMarginalOk | alpm.SigDatabaseUnknownOk},
+		{[]string{"TrustAll", "PackageTrustedOnly"}, alpm.SigPackage, alpm.SigPackage | alpm.SigDatabaseMarginalOk | alpm.SigDatabaseUnknownOk},
+	}
+
+	for _, test := range tests {
+		level, err := toSigLevel(test.values, test.base)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %s", test.values, err)
+		}
+		if level != test.expected {
+			t.Fatalf("%v: expected %b got %b", test.values, test.expected, level)
+		}
+	}
+
+	if _, err := toSigLevel([]string{"Sometimes"}, defaultSigLevel); err == nil {
+		t.Fatalf("Expected an error for an invalid SigLevel")
+	}
 }
@@ -92,6 +92,10 @@ type target struct {
 func toTarget(pkg string) target {
 	db, dep := splitDbFromName(pkg)
 	name, mod, version := splitDep(dep)
+	if mod == "==" {
+		// Accept the comparison operator as well as pacman's "="
+		mod = "="
+	}
 
 	return target{
 		db,
@@ -315,7 +315,7 @@ func (ds *depSolver) CheckConflicts() (mapStringSet, error) {
 
 	if len(conflicts) > 0 {
 		if !config.UseAsk {
-			if config.NoConfirm {
+			if config.NoConfirm && !cmdArgs.existsArg("plan", "p", "print") {
 				return nil, fmt.Errorf("Package conflicts can not be resolved with noconfirm, aborting")
 			}
 
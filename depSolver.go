@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"sort"
 	"strings"
 	"sync"
@@ -21,6 +22,12 @@ type depSolver struct {
 	SyncDb   alpm.DbList
 	Seen     stringSet
 	Warnings *aurWarnings
+	// Revisions maps AUR bases that must be built from an older commit to
+	// that commit.
+	Revisions map[string]srcinfoRevision
+	// ReadOnly stops AUR repos from being cloned or fetched while resolving
+	// so that printing a plan changes nothing.
+	ReadOnly bool
 }
 
 func makeDepSolver() (*depSolver, error) {
@@ -45,16 +52,19 @@ func makeDepSolver() (*depSolver, error) {
 		syncDb,
 		make(stringSet),
 		nil,
+		make(map[string]srcinfoRevision),
+		false,
 	}, nil
 }
 
-func getDepSolver(pkgs []string, warnings *aurWarnings) (*depSolver, error) {
+func getDepSolver(pkgs []string, warnings *aurWarnings, readOnly bool) (*depSolver, error) {
 	ds, err := makeDepSolver()
 	if err != nil {
 		return nil, err
 	}
 
 	ds.Warnings = warnings
+	ds.ReadOnly = readOnly
 	err = ds.resolveTargets(pkgs)
 	if err != nil {
 		return nil, err
@@ -70,6 +80,7 @@ func (ds *depSolver) resolveTargets(pkgs []string) error {
 	// Combine as many AUR package requests as possible into a single RPC
 	// call
 	aurTargets := make([]string, 0)
+	versioned := make([]target, 0)
 	pkgs = removeInvalidTargets(pkgs)
 
 	for _, pkg := range pkgs {
@@ -92,6 +103,9 @@ func (ds *depSolver) resolveTargets(pkgs []string) error {
 		if target.Db == "aur" || mode == modeAUR {
 			ds.Targets = append(ds.Targets, target)
 			aurTargets = append(aurTargets, target.DepString())
+			if target.Mod == "=" {
+				versioned = append(versioned, target)
+			}
 			continue
 		}
 
@@ -134,12 +148,18 @@ func (ds *depSolver) resolveTargets(pkgs []string) error {
 		//if there was no db prefix check the aur
 		if target.Db == "" {
 			aurTargets = append(aurTargets, target.DepString())
+			if target.Mod == "=" {
+				versioned = append(versioned, target)
+			}
 		}
 
 		ds.Targets = append(ds.Targets, target)
 	}
 
 	if len(aurTargets) > 0 && (mode == modeAny || mode == modeAUR) {
+		if err := ds.resolveAURVersions(versioned); err != nil {
+			return err
+		}
 		return ds.resolveAURPackages(aurTargets, true)
 	}
 
@@ -330,6 +350,10 @@ func (ds *depSolver) cacheAURPackages(_pkgs []string) error {
 	}
 
 	for _, pkg := range info {
+		// Bases resolved from an older commit keep that commit's info
+		if _, ok := ds.Revisions[pkg.PackageBase]; ok {
+			continue
+		}
 		// Dump everything in cache just in case we need it later
 		ds.AurCache[pkg.Name] = pkg
 	}
@@ -338,8 +362,8 @@ func (ds *depSolver) cacheAURPackages(_pkgs []string) error {
 }
 
 // Pseudo provides finder.
-// Try to find provides by performing a search of the package name
-// This effectively performs -Ss on each package
+// Try to find provides by asking the backend for possible providers of each
+// package. For the AUR this effectively performs -Ss on each package
 // then runs -Si on each result to cache the information.
 //
 // For example if you were to -S yay then yay -Ss would give:
@@ -355,21 +379,7 @@ func (ds *depSolver) findProvides(pkgs stringSet) error {
 
 	doSearch := func(pkg string) {
 		defer wg.Done()
-		var err error
-		var results []rpc.Pkg
-
-		// Hack for a bigger search result, if the user wants
-		// java-envronment we can search for just java instead and get
-		// more hits.
-		words := strings.Split(pkg, "-")
-
-		for i := range words {
-			results, err = rpc.SearchByNameDesc(strings.Join(words[:i+1], "-"))
-			if err == nil {
-				break
-			}
-		}
-
+		results, err := aurClient.Provides(pkg)
 		if err != nil {
 			return
 		}
@@ -539,6 +549,58 @@ func (ds *depSolver) Print() {
 	printDownloads("Aur Make", aurMakeLen, aurMake)
 }
 
+// PrintPlan prints what an install would do in the order it would be done.
+func (ds *depSolver) PrintPlan(conflicts mapStringSet) {
+	ds.Print()
+
+	if len(ds.Repo) > 0 {
+		fmt.Println()
+		fmt.Println(bold(cyan("::") + bold(" Repo packages to install:")))
+		for _, pkg := range ds.Repo {
+			str := "    " + pkg.DB().Name() + "/" + cyan(pkg.Name()+"-"+pkg.Version())
+			if !ds.Runtime.get(pkg.Name()) {
+				str += " " + yellow("(make)")
+			}
+			fmt.Println(str)
+		}
+	}
+
+	if len(ds.Aur) > 0 {
+		fmt.Println()
+		fmt.Println(bold(cyan("::") + bold(" AUR packages to build, in order:")))
+		for n, base := range ds.Aur {
+			str := fmt.Sprintf("%5d %s", n+1, cyan(base.Pkgbase()+"-"+base.Version()))
+			if len(base) > 1 || base.Pkgbase() != base[0].Name {
+				names := make([]string, 0, len(base))
+				for _, pkg := range base {
+					names = append(names, pkg.Name)
+				}
+				str += " (" + strings.Join(names, " ") + ")"
+			}
+
+			if !ds.isRuntimeBase(base) {
+				str += " " + yellow("(make)")
+			}
+
+			fmt.Println(str)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		names := make([]string, 0, len(conflicts))
+		for name := range conflicts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Println()
+		fmt.Println(bold(cyan("::") + bold(" Packages to remove due to conflicts:")))
+		for _, name := range names {
+			fmt.Println("    " + cyan(name) + " replaces " + strings.Join(conflicts[name].toSlice(), ", "))
+		}
+	}
+}
+
 func (ds *depSolver) resolveRuntime() {
 	for _, pkg := range ds.Repo {
 		if ds.Explicit.get(pkg.Name()) {
@@ -601,6 +663,17 @@ func (ds *depSolver) resolveRuntimeAur(pkg *rpc.Pkg) {
 	}
 }
 
+// isRuntimeBase returns true if any package of base is needed at runtime.
+func (ds *depSolver) isRuntimeBase(base Base) bool {
+	for _, pkg := range base {
+		if ds.Runtime.get(pkg.Name) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (ds *depSolver) HasMake() bool {
 	lenAur := 0
 	for _, base := range ds.Aur {
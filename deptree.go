@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	alpm "github.com/jguer/go-alpm"
+	rpc "github.com/mikkeloscar/aur"
+)
+
+// Node kinds of a dependency graph.
+const (
+	nodeAUR       = "aur"
+	nodeRepo      = "repo"
+	nodeInstalled = "installed"
+)
+
+type depNode struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// depEdge says From needs To. Type is depends, makedepends or checkdepends,
+// Provides is set when To only satisfies the dependency through a provide.
+type depEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Type     string `json:"type"`
+	Provides string `json:"provides,omitempty"`
+}
+
+func (e depEdge) label() string {
+	if e.Provides != "" {
+		return e.Type + ", provides " + e.Provides
+	}
+	return e.Type
+}
+
+type depGraph struct {
+	Roots []string   `json:"roots"`
+	Nodes []*depNode `json:"nodes"`
+	Edges []depEdge  `json:"edges"`
+
+	nodes map[string]*depNode
+}
+
+func (g *depGraph) addNode(name, version, kind string) bool {
+	if _, ok := g.nodes[name]; ok {
+		return false
+	}
+
+	node := &depNode{name, version, kind}
+	g.nodes[name] = node
+	g.Nodes = append(g.Nodes, node)
+	return true
+}
+
+func (g *depGraph) children(name string) []depEdge {
+	edges := make([]depEdge, 0)
+	for _, edge := range g.Edges {
+		if edge.From == name {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// Graph builds the dependency graph of everything the solver resolved,
+// starting from the explicitly requested packages. Dependencies that are
+// already installed are included but not walked any further.
+func (ds *depSolver) Graph() *depGraph {
+	g := &depGraph{
+		Roots: make([]string, 0),
+		Nodes: make([]*depNode, 0),
+		Edges: make([]depEdge, 0),
+		nodes: make(map[string]*depNode),
+	}
+
+	var walkAur func(pkg *rpc.Pkg)
+	var walkRepo func(pkg *alpm.Package)
+
+	// link adds an edge to whatever satisfies dep and returns what needs to
+	// be walked next.
+	link := func(from string, dep string, depType string) (*rpc.Pkg, *alpm.Package) {
+		depName, _, _ := splitDep(dep)
+		edge := depEdge{From: from, Type: depType}
+
+		var aurPkg *rpc.Pkg
+		var repoPkg *alpm.Package
+
+		if pkg, err := ds.LocalDb.PkgCache().FindSatisfier(dep); err == nil {
+			edge.To = pkg.Name()
+			g.addNode(pkg.Name(), pkg.Version(), nodeInstalled)
+		} else if pkg := ds.findSatisfierAur(dep); pkg != nil {
+			edge.To = pkg.Name
+			if g.addNode(pkg.Name, pkg.Version, nodeAUR) {
+				aurPkg = pkg
+			}
+		} else if pkg := ds.findSatisfierRepo(dep); pkg != nil {
+			edge.To = pkg.Name()
+			if g.addNode(pkg.Name(), pkg.Version(), nodeRepo) {
+				repoPkg = pkg
+			}
+		} else {
+			return nil, nil
+		}
+
+		if edge.To != depName {
+			edge.Provides = dep
+		}
+
+		g.Edges = append(g.Edges, edge)
+		return aurPkg, repoPkg
+	}
+
+	walk := func(from string, deps []string, depType string) {
+		for _, dep := range deps {
+			aurPkg, repoPkg := link(from, dep, depType)
+			if aurPkg != nil {
+				walkAur(aurPkg)
+			} else if repoPkg != nil {
+				walkRepo(repoPkg)
+			}
+		}
+	}
+
+	walkAur = func(pkg *rpc.Pkg) {
+		walk(pkg.Name, pkg.Depends, "depends")
+		walk(pkg.Name, pkg.MakeDepends, "makedepends")
+		walk(pkg.Name, pkg.CheckDepends, "checkdepends")
+	}
+
+	walkRepo = func(pkg *alpm.Package) {
+		deps := make([]string, 0)
+		pkg.Depends().ForEach(func(dep alpm.Depend) error {
+			deps = append(deps, dep.String())
+			return nil
+		})
+		walk(pkg.Name(), deps, "depends")
+	}
+
+	for _, base := range ds.Aur {
+		for _, pkg := range base {
+			if ds.Explicit.get(pkg.Name) && g.addNode(pkg.Name, pkg.Version, nodeAUR) {
+				g.Roots = append(g.Roots, pkg.Name)
+				walkAur(pkg)
+			}
+		}
+	}
+
+	for _, pkg := range ds.Repo {
+		if ds.Explicit.get(pkg.Name()) && g.addNode(pkg.Name(), pkg.Version(), nodeRepo) {
+			g.Roots = append(g.Roots, pkg.Name())
+			walkRepo(pkg)
+		}
+	}
+
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].Name < g.Nodes[j].Name })
+	return g
+}
+
+func (n *depNode) colour() string {
+	switch n.Kind {
+	case nodeAUR:
+		return magenta("(" + n.Kind + ")")
+	case nodeRepo:
+		return cyan("(" + n.Kind + ")")
+	}
+	return green("(" + n.Kind + ")")
+}
+
+// printTree prints the graph as a tree to w. A package that appears more than
+// once is only expanded the first time.
+func (g *depGraph) printTree(w io.Writer) {
+	expanded := make(stringSet)
+
+	var printNode func(name, prefix, label string, last bool, root bool)
+	printNode = func(name, prefix, label string, last bool, root bool) {
+		node := g.nodes[name]
+		str := bold(node.Name) + " " + node.Version + " " + node.colour()
+		if label != "" {
+			str += " " + yellow(label)
+		}
+
+		childPrefix := prefix
+		if !root {
+			if last {
+				str = prefix + "└─ " + str
+				childPrefix += "   "
+			} else {
+				str = prefix + "├─ " + str
+				childPrefix += "│  "
+			}
+		}
+
+		children := g.children(name)
+		if expanded.get(name) && len(children) > 0 {
+			fmt.Fprintln(w, str+" ...")
+			return
+		}
+
+		fmt.Fprintln(w, str)
+		expanded.set(name)
+
+		for i, edge := range children {
+			printNode(edge.To, childPrefix, edge.label(), i == len(children)-1, false)
+		}
+	}
+
+	for _, root := range g.Roots {
+		printNode(root, "", "", true, true)
+	}
+}
+
+// printDOT prints the graph to w in Graphviz DOT format.
+func (g *depGraph) printDOT(w io.Writer) {
+	shapes := map[string]string{
+		nodeAUR:       "box",
+		nodeRepo:      "ellipse",
+		nodeInstalled: "note",
+	}
+
+	fmt.Fprintln(w, "digraph dependencies {")
+	for _, node := range g.Nodes {
+		// Quoting turns the newline into the \n escape DOT breaks lines on
+		label := node.Name + "\n" + node.Version + " (" + node.Kind + ")"
+		fmt.Fprintf(w, "\t%s [label=%s, shape=%s];\n", strconv.Quote(node.Name), strconv.Quote(label), shapes[node.Kind])
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(w, "\t%s -> %s [label=%s];\n", strconv.Quote(edge.From), strconv.Quote(edge.To), strconv.Quote(edge.label()))
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// printDepTree resolves targets and prints their dependency tree.
+func printDepTree(targets []string) error {
+	if len(targets) == 0 {
+		return fmt.Errorf("no targets specified")
+	}
+
+	warnings := &aurWarnings{}
+	ds, err := getDepSolver(targets, warnings, true)
+	if err != nil {
+		return err
+	}
+
+	if err = ds.CheckMissing(); err != nil {
+		return err
+	}
+
+	g := ds.Graph()
+
+	switch {
+	case cmdArgs.existsArg("json"):
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "\t")
+		return encoder.Encode(g)
+	case cmdArgs.existsArg("dot"):
+		g.printDOT(os.Stdout)
+	default:
+		g.printTree(os.Stdout)
+		if len(warnings.Missing) > 0 {
+			fmt.Fprintln(os.Stderr, bold(yellow(arrow)), "Missing AUR packages:", strings.Join(warnings.Missing, " "))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// testGraph builds a graph from nodes given as name, version and kind.
+func testGraph(roots []string, nodes [][3]string, edges []depEdge) *depGraph {
+	g := &depGraph{Roots: roots, Edges: edges, nodes: make(map[string]*depNode)}
+	for _, node := range nodes {
+		g.addNode(node[0], node[1], node[2])
+	}
+	return g
+}
+
+func TestPrintDepGraph(t *testing.T) {
+	useColor = false
+
+	testCases := []struct {
+		name  string
+		graph *depGraph
+		tree  string
+		dot   string
+	}{
+		{
+			"shared",
+			testGraph(
+				[]string{"foo"},
+				[][3]string{{"foo", "1.0-1", nodeAUR}, {"bar", "2.0-1", nodeRepo}, {"glibc", "2.29-1", nodeInstalled}},
+				[]depEdge{
+					{"foo", "bar", "depends", ""},
+					{"foo", "glibc", "makedepends", "libc"},
+					{"bar", "glibc", "depends", ""},
+				},
+			),
+			"foo 1.0-1 (aur)\n" +
+				"├─ bar 2.0-1 (repo) depends\n" +
+				"│  └─ glibc 2.29-1 (installed) depends\n" +
+				"└─ glibc 2.29-1 (installed) makedepends, provides libc\n",
+			"digraph dependencies {\n" +
+				"\t\"foo\" [label=\"foo\\n1.0-1 (aur)\", shape=box];\n" +
+				"\t\"bar\" [label=\"bar\\n2.0-1 (repo)\", shape=ellipse];\n" +
+				"\t\"glibc\" [label=\"glibc\\n2.29-1 (installed)\", shape=note];\n" +
+				"\t\"foo\" -> \"bar\" [label=\"depends\"];\n" +
+				"\t\"foo\" -> \"glibc\" [label=\"makedepends, provides libc\"];\n" +
+				"\t\"bar\" -> \"glibc\" [label=\"depends\"];\n" +
+				"}\n",
+		},
+		{
+			"cycle",
+			testGraph(
+				[]string{"a"},
+				[][3]string{{"a", "1-1", nodeAUR}, {"b", "1-1", nodeAUR}},
+				[]depEdge{
+					{"a", "b", "depends", ""},
+					{"b", "a", "checkdepends", ""},
+				},
+			),
+			"a 1-1 (aur)\n" +
+				"└─ b 1-1 (aur) depends\n" +
+				"   └─ a 1-1 (aur) checkdepends ...\n",
+			"digraph dependencies {\n" +
+				"\t\"a\" [label=\"a\\n1-1 (aur)\", shape=box];\n" +
+				"\t\"b\" [label=\"b\\n1-1 (aur)\", shape=box];\n" +
+				"\t\"a\" -> \"b\" [label=\"depends\"];\n" +
+				"\t\"b\" -> \"a\" [label=\"checkdepends\"];\n" +
+				"}\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		var tree, dot bytes.Buffer
+		tc.graph.printTree(&tree)
+		tc.graph.printDOT(&dot)
+
+		if tree.String() != tc.tree {
+			t.Fatalf("%s: expected tree\n%s\ngot\n%s", tc.name, tc.tree, tree.String())
+		}
+		if dot.String() != tc.dot {
+			t.Fatalf("%s: expected DOT\n%s\ngot\n%s", tc.name, tc.dot, dot.String())
+		}
+	}
+}
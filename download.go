@@ -3,24 +3,22 @@ package main
 import (
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"sync"
-
-	alpm "github.com/jguer/go-alpm"
 )
 
 // Decide what download method to use:
 // Use the config option when the destination does not already exits
 // If .git exists in the destination use git
 // Otherwise use a tarrball
+// A local AUR tree has no tarballs so git is always used for it
 func shouldUseGit(path string) bool {
 	_, err := os.Stat(path)
 	if os.IsNotExist(err) {
-		return config.GitClone
+		_, local := aurClient.(*localBackend)
+		return config.GitClone || local
 	}
 
 	_, err = os.Stat(filepath.Join(path, ".git"))
@@ -36,7 +34,7 @@ func downloadFile(path string, url string) (err error) {
 	defer out.Close()
 
 	// Get the data
-	resp, err := http.Get(url)
+	resp, err := httpClient.Get(url)
 	if err != nil {
 		return err
 	}
@@ -47,8 +45,8 @@ func downloadFile(path string, url string) (err error) {
 	return err
 }
 
-func gitHasDiff(path string, name string) (bool, error) {
-	stdout, stderr, err := capture(passToGit(filepath.Join(path, name), "rev-parse", "HEAD", "HEAD@{upstream}"))
+func gitHasDiff(path string, name string, rev string) (bool, error) {
+	stdout, stderr, err := capture(passToGit(filepath.Join(path, name), "rev-parse", "HEAD", rev))
 	if err != nil {
 		return false, fmt.Errorf("%s%s", stderr, err)
 	}
@@ -131,35 +129,71 @@ func downloadAndUnpack(url string, path string) error {
 }
 
 func getPkgbuilds(pkgs []string) error {
-	missing := false
+	var errs MultiError
 	wd, err := os.Getwd()
 	if err != nil {
 		return err
 	}
 
-	pkgs = removeInvalidTargets(pkgs)
-	aur, repo, err := packageSlices(pkgs)
+	versions := make(map[string]string)
+	names := make([]string, 0, len(pkgs))
+	for _, target := range pkgs {
+		name, version := parseVersionTarget(target)
+		if version != "" {
+			_, pkg := splitDbFromName(name)
+			versions[pkg] = version
+		}
+		names = append(names, name)
+	}
+
+	names = removeInvalidTargets(names)
+	aur, repo, err := packageSlices(names)
+	if err != nil {
+		return err
+	}
 
 	for n := range aur {
 		_, pkg := splitDbFromName(aur[n])
 		aur[n] = pkg
 	}
 
+	commit, _, _ := cmdArgs.getArg("commit")
+
+	if cmdArgs.existsArg("log") {
+		if len(repo) > 0 {
+			return fmt.Errorf("--log only works for AUR packages")
+		}
+		if commit != "" {
+			return fmt.Errorf("--log and --commit can not be used together")
+		}
+		return printAURLog(aur, wd)
+	}
+
+	if commit != "" && (len(aur) != 1 || len(repo) != 0) {
+		return fmt.Errorf("--commit needs exactly one AUR package")
+	}
+
+	for _, target := range repo {
+		_, name := splitDbFromName(target)
+		if _, ok := versions[name]; ok {
+			return fmt.Errorf("%s is not an AUR package, versions can only be given for AUR packages", name)
+		}
+	}
+
 	info, err := aurInfoPrint(aur)
 	if err != nil {
 		return err
 	}
 
 	if len(repo) > 0 {
-		missing, err = getPkgbuildsfromABS(repo, wd)
-		if err != nil {
-			return err
-		}
+		errs.Add(getPkgbuildsfromABS(repo, wd))
 	}
 
 	if len(aur) > 0 {
 		allBases := getBases(info)
 		bases := make([]Base, 0)
+		revBases := make([]Base, 0)
+		revisions := make(map[string]string)
 
 		for _, base := range allBases {
 			name := base.Pkgbase()
@@ -177,126 +211,35 @@ func getPkgbuilds(pkgs []string) error {
 				continue
 			}
 
-			bases = append(bases, base)
-		}
-
-		if _, err = downloadPkgbuilds(bases, nil, wd); err != nil {
-			return err
-		}
-
-		missing = missing || len(aur) != len(info)
-	}
-
-	if missing {
-		err = fmt.Errorf("")
-	}
-
-	return err
-}
-
-// GetPkgbuild downloads pkgbuild from the ABS.
-func getPkgbuildsfromABS(pkgs []string, path string) (bool, error) {
-	var wg sync.WaitGroup
-	var mux sync.Mutex
-	var errs MultiError
-	names := make(map[string]string)
-	missing := make([]string, 0)
-	downloaded := 0
-
-	dbList, err := alpmHandle.SyncDbs()
-	if err != nil {
-		return false, err
-	}
-
-	for _, pkgN := range pkgs {
-		var pkg *alpm.Package
-		var err error
-		var url string
-		pkgDb, name := splitDbFromName(pkgN)
+			for _, pkg := range base {
+				if version, ok := versions[pkg.Name]; ok {
+					revisions[name] = version
+				}
+			}
 
-		if pkgDb != "" {
-			if db, err := alpmHandle.SyncDbByName(pkgDb); err == nil {
-				pkg, err = db.PkgByName(name)
+			if _, ok := revisions[name]; ok || commit != "" {
+				revBases = append(revBases, base)
+			} else {
+				bases = append(bases, base)
 			}
-		} else {
-			dbList.ForEach(func(db alpm.Db) error {
-				if pkg, err = db.PkgByName(name); err == nil {
-					return fmt.Errorf("")
-				}
-				return nil
-			})
 		}
 
-		if pkg == nil {
-			missing = append(missing, name)
-			continue
+		if _, err = downloadPkgbuilds(bases, nil, wd); err != nil {
+			return err
 		}
 
-		name = pkg.Base()
-		if name == "" {
-			name = pkg.Name()
-		}
+		errs.Add(getPkgbuildRevisions(revBases, revisions, commit, wd))
 
-		switch pkg.DB().Name() {
-		case "core", "extra", "testing":
-			url = "https://git.archlinux.org/svntogit/packages.git/snapshot/packages/" + name + ".tar.gz"
-		case "community", "multilib", "community-testing", "multilib-testing":
-			url = "https://git.archlinux.org/svntogit/community.git/snapshot/packages/" + name + ".tar.gz"
-		default:
-			missing = append(missing, name)
-			continue
+		found := make(stringSet)
+		for _, pkg := range info {
+			found.set(pkg.Name)
 		}
-
-		_, err = os.Stat(filepath.Join(path, name))
-		if err != nil && !os.IsNotExist(err) {
-			fmt.Fprintln(os.Stderr, bold(red(smallArrow)), err)
-			continue
-		} else if os.IsNotExist(err) || cmdArgs.existsArg("f", "force") {
-			if err = os.RemoveAll(filepath.Join(path, name)); err != nil {
-				fmt.Fprintln(os.Stderr, bold(red(smallArrow)), err)
-				continue
+		for _, name := range aur {
+			if !found.get(name) {
+				errs.Add(fmt.Errorf("%s: package not found in the AUR", name))
 			}
-		} else {
-			fmt.Printf("%s %s %s\n", yellow(smallArrow), cyan(name), "already downloaded -- use -f to overwrite")
-			continue
-		}
-
-		names[name] = url
-	}
-
-	if len(missing) != 0 {
-		fmt.Println(yellow(bold(smallArrow)), "Missing ABS packages: ", cyan(strings.Join(missing, "  ")))
-	}
-
-	download := func(pkg string, url string) {
-		defer wg.Done()
-		if err := downloadAndUnpack(url, cacheHome); err != nil {
-			errs.Add(fmt.Errorf("%s Failed to get pkgbuild: %s: %s", bold(red(arrow)), bold(cyan(pkg)), bold(red(err.Error()))))
-			return
-		}
-
-		_, stderr, err := capture(exec.Command("mv", filepath.Join(cacheHome, "packages", pkg, "trunk"), filepath.Join(path, pkg)))
-		mux.Lock()
-		downloaded++
-		if err != nil {
-			errs.Add(fmt.Errorf("%s Failed to move %s: %s", bold(red(arrow)), bold(cyan(pkg)), bold(red(string(stderr)))))
-		} else {
-			fmt.Printf(bold(cyan("::"))+" Downloaded PKGBUILD from ABS (%d/%d): %s\n", downloaded, len(names), cyan(pkg))
-		}
-		mux.Unlock()
-	}
-
-	count := 0
-	for name, url := range names {
-		wg.Add(1)
-		go download(name, url)
-		count++
-		if count%25 == 0 {
-			wg.Wait()
 		}
 	}
 
-	wg.Wait()
-	errs.Add(os.RemoveAll(filepath.Join(cacheHome, "packages")))
-	return len(missing) != 0, errs.Return()
+	return errs.Return()
 }
@@ -3,9 +3,11 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
@@ -19,6 +21,22 @@ func show(cmd *exec.Cmd) error {
 	return nil
 }
 
+// showOutput is like show but sends the command's output to out. Stdin is
+// only attached when out is stdout as the command may be one of many running
+// at once.
+func showOutput(cmd *exec.Cmd, out io.Writer) error {
+	if out == os.Stdout {
+		return show(cmd)
+	}
+
+	cmd.Stdout, cmd.Stderr = out, out
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("")
+	}
+	return nil
+}
+
 func capture(cmd *exec.Cmd) (string, string, error) {
 	var outbuf, errbuf bytes.Buffer
 
@@ -74,6 +92,13 @@ func waitLock() {
 }
 
 func passToPacman(args *arguments) *exec.Cmd {
+	return passToPacmanWith(args, cmdArgs, config.NoConfirm)
+}
+
+// passToPacmanWith is passToPacman taking the global options from globals and
+// whether to pass --noconfirm from noConfirm, for callers that must not touch
+// cmdArgs or config.
+func passToPacmanWith(args *arguments, globals *arguments, noConfirm bool) *exec.Cmd {
 	argArr := make([]string, 0)
 
 	if args.needRoot() {
@@ -81,9 +106,9 @@ func passToPacman(args *arguments) *exec.Cmd {
 	}
 
 	argArr = append(argArr, config.PacmanBin)
-	argArr = append(argArr, cmdArgs.formatGlobals()...)
+	argArr = append(argArr, globals.formatGlobals()...)
 	argArr = append(argArr, args.formatArgs()...)
-	if config.NoConfirm {
+	if noConfirm {
 		argArr = append(argArr, "--noconfirm")
 	}
 
@@ -102,18 +127,50 @@ func passToMakepkg(dir string, args ...string) *exec.Cmd {
 		args = append(args)
 	}
 
+	override := config.Overrides[filepath.Base(dir)]
+
 	mflags := strings.Fields(config.MFlags)
 	args = append(args, mflags...)
+	args = append(args, strings.Fields(override.MFlags)...)
+
+	makepkgConf := config.MakepkgConf
+	if override.MakepkgConf != "" {
+		makepkgConf = override.MakepkgConf
+	}
+	if makepkgConf != "" {
+		args = append(args, "--config", makepkgConf)
+	}
 
-	if config.MakepkgConf != "" {
-		args = append(args, "--config", config.MakepkgConf)
+	env := make([]string, 0)
+	if srcdest := srcCacheDir(filepath.Base(dir)); srcdest != "" {
+		env = append(env, "SRCDEST="+srcdest)
 	}
+	env = append(env, override.environ()...)
 
 	cmd := exec.Command(config.MakepkgBin, args...)
 	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
 	return cmd
 }
 
+// environ returns the variables the override adds to makepkg's environment.
+func (o pkgOverride) environ() []string {
+	keys := make([]string, 0, len(o.Env))
+	for key := range o.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	env := make([]string, 0, len(keys))
+	for _, key := range keys {
+		env = append(env, key+"="+o.Env[key])
+	}
+
+	return env
+}
+
 func passToGit(dir string, _args ...string) *exec.Cmd {
 	gitflags := strings.Fields(config.GitFlags)
 	args := []string{"-C", dir}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPassToMakepkg(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yay-makepkg")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	config = defaultSettings()
+	config.MakepkgBin = "makepkg"
+	config.MFlags = "--skippgpcheck"
+	config.MakepkgConf = "/etc/makepkg.conf"
+	config.SrcDest = dir
+	config.Overrides = map[string]pkgOverride{
+		"foo": {
+			MFlags:      "--nocheck --skipinteg",
+			MakepkgConf: "/etc/makepkg-clang.conf",
+			Env:         map[string]string{"MAKEFLAGS": "-j4", "CC": "clang"},
+		},
+		"bar": {MFlags: "--nocheck"},
+	}
+	os.MkdirAll(filepath.Join(dir, "bases", "foo"), 0755)
+
+	testCases := []struct {
+		base string
+		args []string
+		env  []string
+	}{
+		{
+			"foo",
+			[]string{"makepkg", "-f", "--skippgpcheck", "--nocheck", "--skipinteg", "--config", "/etc/makepkg-clang.conf"},
+			[]string{"SRCDEST=" + filepath.Join(dir, "bases", "foo"), "CC=clang", "MAKEFLAGS=-j4"},
+		},
+		{
+			"bar",
+			[]string{"makepkg", "-f", "--skippgpcheck", "--nocheck", "--config", "/etc/makepkg.conf"},
+			nil,
+		},
+		{
+			"baz",
+			[]string{"makepkg", "-f", "--skippgpcheck", "--config", "/etc/makepkg.conf"},
+			nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		cmd := passToMakepkg(filepath.Join("/build", tc.base), "-f")
+		if !reflect.DeepEqual(cmd.Args, tc.args) {
+			t.Fatalf("%s: expected args %v got %v", tc.base, tc.args, cmd.Args)
+		}
+
+		var env []string
+		if cmd.Env != nil {
+			env = cmd.Env[len(os.Environ()):]
+		}
+		if !reflect.DeepEqual(env, tc.env) {
+			t.Fatalf("%s: expected env %v got %v", tc.base, tc.env, env)
+		}
+	}
+
+	if err = checkChrootOverrides([]Base{{{Name: "bar", PackageBase: "bar"}}}); err != nil {
+		t.Fatalf("Expected flags to be allowed in a chroot, got %s", err)
+	}
+	if err = checkChrootOverrides([]Base{{{Name: "foo", PackageBase: "foo"}}}); err == nil {
+		t.Fatalf("Expected an error for the overrides of foo in a chroot")
+	}
+}
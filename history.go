@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyPkg is a single package installed during an AUR transaction.
+type historyPkg struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	AsDeps bool   `json:"asdeps"`
+}
+
+// historyBase is a single base built during an AUR transaction.
+type historyBase struct {
+	Pkgbase  string       `json:"pkgbase"`
+	Version  string       `json:"version"`
+	Commit   string       `json:"commit"`
+	Packages []historyPkg `json:"packages"`
+}
+
+// transaction records everything yay built and installed in one run.
+type transaction struct {
+	ID      int           `json:"id"`
+	Date    int64         `json:"date"`
+	Targets []string      `json:"targets"`
+	Bases   []historyBase `json:"bases"`
+}
+
+type history []transaction
+
+func makeTransaction(targets []string) *transaction {
+	return &transaction{
+		Date:    time.Now().Unix(),
+		Targets: append([]string{}, targets...),
+		Bases:   make([]historyBase, 0),
+	}
+}
+
+// addBase records a base that has just been installed.
+func (t *transaction) addBase(base Base, version string, pkgdests map[string]string, asdeps stringSet) {
+	dir := filepath.Join(config.BuildDir, base.Pkgbase())
+	hb := historyBase{
+		Pkgbase:  base.Pkgbase(),
+		Version:  version,
+		Packages: make([]historyPkg, 0, len(base)),
+	}
+
+	if shouldUseGit(dir) {
+		if stdout, _, err := capture(passToGit(dir, "rev-parse", "HEAD")); err == nil {
+			hb.Commit = stdout
+		}
+	}
+
+	for _, pkg := range base {
+		hb.Packages = append(hb.Packages, historyPkg{pkg.Name, pkgdests[pkg.Name], asdeps.get(pkg.Name)})
+	}
+
+	t.Bases = append(t.Bases, hb)
+}
+
+func loadHistory() (history, error) {
+	var h history
+
+	hfile, err := os.Open(historyFile)
+	if os.IsNotExist(err) {
+		return h, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to open history file '%s': %s", historyFile, err)
+	}
+	defer hfile.Close()
+
+	decoder := json.NewDecoder(hfile)
+	if err = decoder.Decode(&h); err != nil {
+		return nil, fmt.Errorf("Failed to read history '%s': %s", historyFile, err)
+	}
+
+	return h, nil
+}
+
+func (h history) save() error {
+	marshalledinfo, err := json.MarshalIndent(h, "", "\t")
+	if err != nil {
+		return err
+	}
+	in, err := os.OpenFile(historyFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_, err = in.Write(marshalledinfo)
+	if err != nil {
+		return err
+	}
+	err = in.Sync()
+	return err
+}
+
+// saveTransaction appends t to the history file giving it the next free ID.
+func saveTransaction(t *transaction) error {
+	h, err := loadHistory()
+	if err != nil {
+		return err
+	}
+
+	t.ID = 1
+	if len(h) > 0 {
+		t.ID = h[len(h)-1].ID + 1
+	}
+
+	return append(h, *t).save()
+}
+
+func (h history) find(id int) *transaction {
+	for i := range h {
+		if h[i].ID == id {
+			return &h[i]
+		}
+	}
+
+	return nil
+}
+
+// printHistory lists every recorded AUR transaction.
+func printHistory() error {
+	h, err := loadHistory()
+	if err != nil {
+		return err
+	}
+
+	if len(h) == 0 {
+		fmt.Println(" there is nothing to do")
+		return nil
+	}
+
+	for _, t := range h {
+		fmt.Printf("%s %s %s\n", bold(magenta(strconv.Itoa(t.ID))), bold(formatTime(int(t.Date))), strings.Join(t.Targets, " "))
+
+		for _, base := range t.Bases {
+			names := make([]string, 0, len(base.Packages))
+			for _, pkg := range base.Packages {
+				names = append(names, pkg.Name)
+			}
+
+			str := "    " + cyan(base.Pkgbase+"-"+base.Version)
+			// A hand edited history may list no packages for a base
+			if len(names) > 1 || (len(names) == 1 && names[0] != base.Pkgbase) {
+				str += " (" + strings.Join(names, " ") + ")"
+			}
+
+			fmt.Println(str)
+		}
+	}
+
+	return nil
+}
+
+// rollback reinstalls the packages from a previous transaction. Package files
+// still in the build dir are installed as is, otherwise the base is rebuilt
+// from the commit that was recorded when it was first built.
+func rollback(idStr string) error {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return fmt.Errorf("invalid transaction id: %s", idStr)
+	}
+
+	h, err := loadHistory()
+	if err != nil {
+		return err
+	}
+
+	t := h.find(id)
+	if t == nil {
+		return fmt.Errorf("transaction %d not found", id)
+	}
+
+	arguments := makeArguments()
+	arguments.addArg("U")
+	depArguments := makeArguments()
+	depArguments.addArg("D", "asdeps")
+
+	for _, base := range t.Bases {
+		pkgdests, err := rollbackBase(base)
+		if err != nil {
+			return err
+		}
+
+		for _, pkg := range base.Packages {
+			arguments.addTarget(pkgdests[pkg.Name])
+			if pkg.AsDeps {
+				depArguments.addTarget(pkg.Name)
+			}
+		}
+	}
+
+	if err = show(passToPacman(arguments)); err != nil {
+		return err
+	}
+
+	if len(depArguments.targets) > 0 {
+		_, stderr, err := capture(passToPacman(depArguments))
+		if err != nil {
+			return fmt.Errorf("%s%s", stderr, err)
+		}
+	}
+
+	return nil
+}
+
+// rollbackBase returns the package files recorded for base, rebuilding them
+// if any no longer exist.
+func rollbackBase(base historyBase) (map[string]string, error) {
+	pkgdests := make(map[string]string)
+	exists := true
+
+	for _, pkg := range base.Packages {
+		pkgdests[pkg.Name] = pkg.Path
+		if _, err := os.Stat(pkg.Path); err != nil {
+			exists = false
+		}
+	}
+
+	if exists {
+		fmt.Println(bold(yellow(arrow)), cyan(base.Pkgbase+"-"+base.Version)+bold(" found in cache -- skipping build"))
+		return pkgdests, nil
+	}
+
+	if base.Commit == "" {
+		return nil, fmt.Errorf("%s: package files are missing and no commit was recorded", base.Pkgbase)
+	}
+
+	if _, err := gitDownload(aurGitURL(base.Pkgbase), config.BuildDir, base.Pkgbase); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(config.BuildDir, base.Pkgbase)
+	_, stderr, err := capture(passToGit(dir, "reset", "--hard", base.Commit))
+	if err != nil {
+		return nil, fmt.Errorf("error resetting %s: %s", base.Pkgbase, stderr)
+	}
+
+	err = show(passToMakepkg(dir, "-cf", "--noconfirm"))
+	if err != nil {
+		return nil, fmt.Errorf("Error making: %s", base.Pkgbase)
+	}
+
+	built, _, err := parsePackageList(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range base.Packages {
+		pkgdest, ok := built[pkg.Name]
+		if !ok {
+			return nil, fmt.Errorf("Could not find PKGDEST for: %s", pkg.Name)
+		}
+		pkgdests[pkg.Name] = pkgdest
+	}
+
+	return pkgdests, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveTransaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yay-history")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldHistoryFile := historyFile
+	historyFile = filepath.Join(dir, historyFileName)
+	defer func() { historyFile = oldHistoryFile }()
+
+	h, err := loadHistory()
+	if err != nil || len(h) != 0 {
+		t.Fatalf("Expected empty history got %v: %v", h, err)
+	}
+
+	for _, targets := range [][]string{{"foo"}, {"bar", "baz"}} {
+		trans := makeTransaction(targets)
+		trans.Bases = append(trans.Bases, historyBase{Pkgbase: targets[0], Version: "1.0-1"})
+		if err = saveTransaction(trans); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	h, err = loadHistory()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(h) != 2 || h[0].ID != 1 || h[1].ID != 2 {
+		t.Fatalf("Expected transactions 1 and 2 got %v", h)
+	}
+
+	if trans := h.find(2); trans == nil || trans.Bases[0].Pkgbase != "bar" {
+		t.Fatalf("Expected transaction 2 to be bar got %v", trans)
+	}
+
+	if trans := h.find(3); trans != nil {
+		t.Fatalf("Expected no transaction 3 got %v", trans)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// httpClient is used for every request yay makes itself. It is replaced by
+// initHTTPClient once the config has been read.
+var httpClient = http.DefaultClient
+
+// userAgentTransport sets the User-Agent of every request.
+type userAgentTransport struct {
+	userAgent string
+	transport http.RoundTripper
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it is given.
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set("User-Agent", t.userAgent)
+
+	return t.transport.RoundTrip(r)
+}
+
+// makeHTTPClient builds a client from the HTTP settings in the config.
+func makeHTTPClient() (*http.Client, error) {
+	proxy := http.ProxyFromEnvironment
+	if config.HTTPProxy != "" {
+		proxyURL, err := url.Parse(config.HTTPProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy '%s': %s", config.HTTPProxy, err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	var tlsConfig *tls.Config
+	if config.CAFile != "" {
+		pem, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA file: %s", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file '%s'", config.CAFile)
+		}
+
+		tlsConfig = &tls.Config{RootCAs: pool}
+	}
+
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		MaxIdleConns:          100,
+		MaxConnsPerHost:       config.MaxConnections,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	userAgent := config.UserAgent
+	if userAgent == "" {
+		userAgent = "yay/" + version
+	}
+
+	return &http.Client{
+		Transport: userAgentTransport{userAgent, transport},
+		Timeout:   time.Duration(config.HTTPTimeout) * time.Second,
+	}, nil
+}
+
+// initHTTPClient sets up the shared client. The proxy and CA file are also
+// exported for git and makepkg.
+func initHTTPClient() error {
+	client, err := makeHTTPClient()
+	if err != nil {
+		return err
+	}
+
+	httpClient = client
+
+	if config.HTTPProxy != "" {
+		for _, env := range []string{"http_proxy", "https_proxy", "HTTP_PROXY", "HTTPS_PROXY"} {
+			os.Setenv(env, config.HTTPProxy)
+		}
+	}
+
+	if config.CAFile != "" {
+		os.Setenv("GIT_SSL_CAINFO", config.CAFile)
+		os.Setenv("CURL_CA_BUNDLE", config.CAFile)
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMakeHTTPClient(t *testing.T) {
+	oldConfig := config
+	defer func() { config = oldConfig }()
+
+	testCases := []struct {
+		proxy     string
+		timeout   int
+		userAgent string
+		expected  string
+	}{
+		{"", 30, "", "yay/" + version},
+		{"http://proxy.example.com:3128", 5, "", "yay/" + version},
+		{"socks5://127.0.0.1:1080", 0, "custom/1.0", "custom/1.0"},
+	}
+
+	var agent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent = r.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	for _, tc := range testCases {
+		config = defaultSettings()
+		config.HTTPProxy = tc.proxy
+		config.HTTPTimeout = tc.timeout
+		config.UserAgent = tc.userAgent
+
+		client, err := makeHTTPClient()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tc.proxy, err)
+		}
+
+		if client.Timeout != time.Duration(tc.timeout)*time.Second {
+			t.Fatalf("%s: expected a timeout of %ds, got %s", tc.proxy, tc.timeout, client.Timeout)
+		}
+
+		transport := client.Transport.(userAgentTransport).transport.(*http.Transport)
+		req, _ := http.NewRequest("GET", "https://aur.archlinux.org/rpc.php", nil)
+		proxy, err := transport.Proxy(req)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tc.proxy, err)
+		}
+		if tc.proxy != "" && (proxy == nil || proxy.String() != tc.proxy) {
+			t.Fatalf("expected proxy %s, got %v", tc.proxy, proxy)
+		}
+
+		if tc.proxy == "" {
+			if _, err = client.Get(server.URL); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if agent != tc.expected {
+				t.Fatalf("expected User-Agent %s, got %s", tc.expected, agent)
+			}
+		}
+	}
+
+	config = defaultSettings()
+	config.HTTPProxy = "http://[::1"
+	if _, err := makeHTTPClient(); err == nil {
+		t.Fatalf("expected an error for an invalid proxy")
+	}
+}
+
+func TestInitHTTPClient(t *testing.T) {
+	oldConfig, oldClient := config, httpClient
+	defer func() { config, httpClient = oldConfig, oldClient }()
+
+	defaultClient, defaultTransport := http.DefaultClient, http.DefaultTransport
+	config = defaultSettings()
+	config.HTTPTimeout = 7
+
+	if err := initHTTPClient(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if httpClient.Timeout != 7*time.Second {
+		t.Fatalf("expected the shared client to be configured, got a timeout of %s", httpClient.Timeout)
+	}
+	if http.DefaultClient != defaultClient || http.DefaultTransport != defaultTransport {
+		t.Fatalf("expected the default client and transport to be left alone")
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -25,8 +28,14 @@ func install(parser *arguments) error {
 
 	warnings := &aurWarnings{}
 	removeMake := false
+	planOnly := parser.existsArg("plan", "p", "print")
 
-	if mode == modeAny || mode == modeRepo {
+	if planOnly {
+		// A plan changes nothing, not even the sync databases
+		if parser.existsArg("y", "refresh") {
+			fmt.Fprintln(os.Stderr, bold(yellow(smallArrow)), bold("Not refreshing the databases while printing a plan"))
+		}
+	} else if mode == modeAny || mode == modeRepo {
 		if config.CombinedUpgrade {
 			if parser.existsArg("y", "refresh") {
 				err = earlyRefresh(parser)
@@ -72,7 +81,7 @@ func install(parser *arguments) error {
 
 	//if we are doing -u also request all packages needing update
 	if parser.existsArg("u", "sysupgrade") {
-		aurUp, repoUp, err = upList(warnings)
+		aurUp, repoUp, err = upList(warnings, os.Stdout)
 		if err != nil {
 			return err
 		}
@@ -109,7 +118,7 @@ func install(parser *arguments) error {
 
 	targets := sliceToStringSet(parser.targets)
 
-	ds, err := getDepSolver(requestTargets, warnings)
+	ds, err := getDepSolver(requestTargets, warnings, planOnly)
 	if err != nil {
 		return err
 	}
@@ -119,6 +128,16 @@ func install(parser *arguments) error {
 		return err
 	}
 
+	if planOnly {
+		conflicts, err := ds.CheckConflicts()
+		if err != nil {
+			return err
+		}
+
+		ds.PrintPlan(conflicts)
+		return nil
+	}
+
 	if len(ds.Aur) == 0 {
 		if !config.CombinedUpgrade {
 			if parser.existsArg("u", "sysupgrade") {
@@ -137,12 +156,22 @@ func install(parser *arguments) error {
 		return fmt.Errorf(bold(red(arrow)) + " Refusing to install AUR Packages as root, Aborting.")
 	}
 
+	if config.Chroot {
+		if err = checkChrootOverrides(ds.Aur); err != nil {
+			return err
+		}
+	}
+
 	conflicts, err := ds.CheckConflicts()
 	if err != nil {
 		return err
 	}
 
 	for _, pkg := range ds.Repo {
+		// Make dependencies are installed in the chroot instead.
+		if config.Chroot && !ds.Runtime.get(pkg.Name()) {
+			continue
+		}
 		arguments.addTarget(pkg.DB().Name() + "/" + pkg.Name())
 	}
 
@@ -158,7 +187,7 @@ func install(parser *arguments) error {
 	ds.Print()
 	fmt.Println()
 
-	if ds.HasMake() {
+	if ds.HasMake() && !config.Chroot {
 		if config.RemoveMake == "yes" {
 			removeMake = true
 		} else if config.RemoveMake == "no" {
@@ -197,7 +226,7 @@ func install(parser *arguments) error {
 		}
 
 		if len(toDiff) > 0 {
-			err = showPkgbuildDiffs(toDiff, cloned)
+			err = showPkgbuildDiffs(toDiff, cloned, ds.Revisions)
 			if err != nil {
 				return err
 			}
@@ -219,6 +248,16 @@ func install(parser *arguments) error {
 		return err
 	}
 
+	err = checkoutAURRevisions(ds.Revisions)
+	if err != nil {
+		return err
+	}
+
+	err = patchPkgbuilds(ds.Aur)
+	if err != nil {
+		return err
+	}
+
 	srcinfos, err = parseSrcinfoFiles(ds.Aur, true)
 	if err != nil {
 		return err
@@ -249,6 +288,14 @@ func install(parser *arguments) error {
 		config.NoConfirm = oldValue
 	}
 
+	if config.ReviewCmds != "" {
+		results := reviewPkgbuilds(ds.Aur, srcinfos)
+		err = reviewNumberMenu(ds.Aur, results)
+		if err != nil {
+			return err
+		}
+	}
+
 	incompatible, err = getIncompatible(ds.Aur, srcinfos)
 	if err != nil {
 		return err
@@ -306,39 +353,22 @@ func install(parser *arguments) error {
 
 	go updateCompletion(false)
 
-	err = downloadPkgbuildsSources(ds.Aur, incompatible)
-	if err != nil {
-		return err
-	}
-
-	err = buildInstallPkgbuilds(ds, srcinfos, parser, incompatible, conflicts)
+	err = downloadPkgbuildsSources(ds.Aur, srcinfos, incompatible)
 	if err != nil {
 		return err
 	}
 
-	if removeMake {
-		removeArguments := makeArguments()
-		removeArguments.addArg("R", "u")
-
-		for _, pkg := range ds.getMake() {
-			removeArguments.addTarget(pkg)
-		}
-
-		oldValue := config.NoConfirm
-		config.NoConfirm = true
-		err = show(passToPacman(removeArguments))
-		config.NoConfirm = oldValue
-
-		if err != nil {
-			return err
-		}
+	sess := makeSession(parser, ds, incompatible, conflicts, removeMake)
+	if err = sess.save(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 	}
 
-	if config.CleanAfter {
-		cleanAfter(ds.Aur)
+	err = buildInstallPkgbuilds(ds, srcinfos, parser, incompatible, conflicts, sess)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return sess.finish()
 }
 
 func inRepos(syncDb alpm.DbList, pkg string) bool {
@@ -405,6 +435,8 @@ func earlyRefresh(parser *arguments) error {
 	arguments := parser.copy()
 	parser.delArg("y", "refresh")
 	arguments.delArg("u", "sysupgrade")
+	arguments.delArg("p", "print")
+	arguments.delArg("plan")
 	arguments.delArg("s", "search")
 	arguments.delArg("i", "info")
 	arguments.delArg("l", "list")
@@ -676,17 +708,24 @@ func editDiffNumberMenu(bases []Base, installed stringSet, diff bool) ([]Base, e
 	return toEdit, nil
 }
 
-func showPkgbuildDiffs(bases []Base, cloned stringSet) error {
+// showPkgbuildDiffs shows what changed in each base since it was last built.
+// Bases built from an older commit are compared against that commit rather
+// than the upstream branch.
+func showPkgbuildDiffs(bases []Base, cloned stringSet, revisions map[string]srcinfoRevision) error {
 	for _, base := range bases {
 		pkg := base.Pkgbase()
 		dir := filepath.Join(config.BuildDir, pkg)
 		if shouldUseGit(dir) {
 			start := "HEAD"
+			end := "HEAD@{upstream}"
+			if rev, ok := revisions[pkg]; ok {
+				end = rev.Commit
+			}
 
 			if cloned.get(pkg) {
 				start = gitEmptyTree
 			} else {
-				hasDiff, err := gitHasDiff(config.BuildDir, pkg)
+				hasDiff, err := gitHasDiff(config.BuildDir, pkg, end)
 				if err != nil {
 					return err
 				}
@@ -697,7 +736,7 @@ func showPkgbuildDiffs(bases []Base, cloned stringSet) error {
 				}
 			}
 
-			args := []string{"diff", start + "..HEAD@{upstream}", "--src-prefix", dir + "/", "--dst-prefix", dir + "/", "--", ".", ":(exclude).SRCINFO"}
+			args := []string{"diff", start + ".." + end, "--src-prefix", dir + "/", "--dst-prefix", dir + "/", "--", ".", ":(exclude).SRCINFO"}
 			if useColor {
 				args = append(args, "--color=always")
 			} else {
@@ -707,6 +746,14 @@ func showPkgbuildDiffs(bases []Base, cloned stringSet) error {
 			if err != nil {
 				return err
 			}
+
+			var prev *pkgbuildSnapshot
+			if !cloned.get(pkg) {
+				prev, _ = gitSnapshot(dir, "HEAD")
+			}
+			if next, err := gitSnapshot(dir, end); err == nil {
+				printPkgbuildRisks(base, pkgbuildRisks(prev, next))
+			}
 		} else {
 			args := []string{"diff"}
 			if useColor {
@@ -717,6 +764,10 @@ func showPkgbuildDiffs(bases []Base, cloned stringSet) error {
 			args = append(args, "--no-index", "/var/empty", dir)
 			// git always returns 1. why? I have no idea
 			show(passToGit(dir, args...))
+
+			if next, err := dirSnapshot(dir); err == nil {
+				printPkgbuildRisks(base, pkgbuildRisks(nil, next))
+			}
 		}
 	}
 
@@ -835,7 +886,7 @@ func downloadPkgbuilds(bases []Base, toSkip stringSet, buildDir string) (stringS
 		}
 
 		if shouldUseGit(filepath.Join(config.BuildDir, pkg)) {
-			clone, err := gitDownload(config.AURURL+"/"+pkg+".git", buildDir, pkg)
+			clone, err := gitDownload(aurGitURL(pkg), buildDir, pkg)
 			if err != nil {
 				errs.Add(err)
 				return
@@ -875,8 +926,32 @@ func downloadPkgbuilds(bases []Base, toSkip stringSet, buildDir string) (stringS
 	return cloned, errs.Return()
 }
 
-func downloadPkgbuildsSources(bases []Base, incompatible stringSet) (err error) {
-	for _, base := range bases {
+// downloadPkgbuildsSources runs makepkg --verifysource for every base using
+// up to config.DownloadJobs workers. When more than one worker is used the
+// output of each base is printed in one block once it is done. Every base is
+// attempted and the failures are listed at the end along with the .SRCINFO
+// source entries makepkg reported.
+func downloadPkgbuildsSources(bases []Base, srcinfos map[string]*gosrc.Srcinfo, incompatible stringSet) error {
+	cache, err := openSrcCache()
+	if err != nil {
+		return err
+	}
+
+	jobs := config.DownloadJobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs > len(bases) {
+		jobs = len(bases)
+	}
+
+	var wg sync.WaitGroup
+	var mux sync.Mutex
+	failed := make(map[string][]string)
+	downloaded := 0
+	queue := make(chan Base)
+
+	download := func(base Base) {
 		pkg := base.Pkgbase()
 		dir := filepath.Join(config.BuildDir, pkg)
 		args := []string{"--verifysource", "-Ccf"}
@@ -885,189 +960,406 @@ func downloadPkgbuildsSources(bases []Base, incompatible stringSet) (err error)
 			args = append(args, "--ignorearch")
 		}
 
-		err = show(passToMakepkg(dir, args...))
+		var err error
+		var output bytes.Buffer
+		var out io.Writer = &output
+		if jobs == 1 {
+			// Still keep the output to find the sources that failed
+			out = io.MultiWriter(os.Stdout, &output)
+		}
+
+		unlock := func() {}
+		if cache != nil {
+			unlock = cache.lock(srcinfos[pkg])
+			err = cache.link(pkg, srcinfos[pkg])
+		}
+		if err == nil {
+			err = showOutput(passToMakepkg(dir, args...), out)
+		}
+
+		if cache != nil {
+			if adoptErr := cache.adopt(pkg, srcinfos[pkg]); adoptErr != nil {
+				fmt.Fprintln(out, adoptErr)
+			}
+			// Save as we go so an interrupted run leaves no source
+			// out of the index
+			if saveErr := cache.save(); saveErr != nil {
+				fmt.Fprintln(out, saveErr)
+			}
+		}
+		unlock()
+
+		mux.Lock()
+		defer mux.Unlock()
+		downloaded++
+
+		if jobs > 1 {
+			str := bold(cyan("::") + " Downloaded sources (%d/%d): %s\n")
+			fmt.Printf(str, downloaded, len(bases), cyan(base.String()))
+			prefixed := newPrefixWriter(os.Stdout, pkg)
+			prefixed.Write(output.Bytes())
+			prefixed.Flush()
+		}
+
 		if err != nil {
-			return fmt.Errorf("Error downloading sources: %s", cyan(base.String()))
+			failed[pkg] = failedSources(output.String(), archValues(srcinfos[pkg].Source, localArch()))
+		}
+	}
+
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for base := range queue {
+				download(base)
+			}
+		}()
+	}
+
+	for _, base := range bases {
+		queue <- base
+	}
+	close(queue)
+	wg.Wait()
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(failed))
+	for _, base := range bases {
+		sources, ok := failed[base.Pkgbase()]
+		if !ok {
+			continue
+		}
+
+		names = append(names, base.String())
+		fmt.Fprintln(os.Stderr, bold(red(arrow)), bold("Failed to download sources:"), cyan(base.String()))
+		for _, source := range sources {
+			fmt.Fprintln(os.Stderr, "    "+bold(red(smallArrow)), source)
 		}
 	}
 
-	return
+	return fmt.Errorf("Error downloading sources: %s", cyan(strings.Join(names, " ")))
 }
 
-func buildInstallPkgbuilds(ds *depSolver, srcinfos map[string]*gosrc.Srcinfo, parser *arguments, incompatible stringSet, conflicts mapStringSet) error {
+func buildInstallPkgbuilds(ds *depSolver, srcinfos map[string]*gosrc.Srcinfo, parser *arguments, incompatible stringSet, conflicts mapStringSet, sess *session) error {
+	// Builds may run concurrently so look up everything that touches alpm or
+	// cmdArgs now rather than from inside the workers.
+	needed := cmdArgs.existsArg("needed")
+	localVersions := make(map[string]string)
 	for _, base := range ds.Aur {
-		pkg := base.Pkgbase()
-		dir := filepath.Join(config.BuildDir, pkg)
-		built := true
+		for _, split := range base {
+			if alpmpkg, err := ds.LocalDb.PkgByName(split.Name); err == nil {
+				localVersions[split.Name] = alpmpkg.Version()
+			}
+		}
+	}
+
+	repo, err := getLocalRepo()
+	if err != nil {
+		return err
+	}
 
-		srcinfo := srcinfos[pkg]
+	b := getBuilder()
+	if err := b.prepare(); err != nil {
+		return err
+	}
 
-		args := []string{"--nobuild", "-fC"}
+	// The package files of every base built so far. A chroot does not see
+	// what was installed on the host so these are handed to the builder.
+	var mux sync.Mutex
+	built := make(map[int]map[string]string)
+	deps := baseDependencies(ds.Aur)
+	failed := ""
 
-		if incompatible.get(pkg) {
-			args = append(args, "--ignorearch")
-		}
+	build := func(i int, out io.Writer) buildResult {
+		mux.Lock()
+		depFiles := builtDependencies(i, deps, built)
+		mux.Unlock()
 
-		//pkgver bump
-		err := show(passToMakepkg(dir, args...))
+		base := ds.Aur[i]
+		pkgdests, version, skip, err := buildPkgbuild(ds, base, b, depFiles, incompatible, localVersions, needed, out)
 		if err != nil {
-			return fmt.Errorf("Error making: %s", base.String())
+			mux.Lock()
+			if failed == "" {
+				failed = base.Pkgbase()
+			}
+			mux.Unlock()
 		}
+		return buildResult{i, pkgdests, version, skip, err}
+	}
+
+	trans := makeTransaction(parser.targets)
+	install := func(result buildResult) error {
+		mux.Lock()
+		built[result.index] = result.pkgdests
+		mux.Unlock()
 
-		pkgdests, version, err := parsePackageList(dir)
+		base := ds.Aur[result.index]
+
+		// Make dependencies only need to exist inside the chroot.
+		if result.skip || (config.Chroot && !ds.isRuntimeBase(base)) {
+			sess.setInstalled(base.Pkgbase())
+			return nil
+		}
+
+		asdeps, err := installPkgbuild(ds, base, srcinfos[base.Pkgbase()], result.pkgdests, repo, parser, conflicts)
 		if err != nil {
+			mux.Lock()
+			failed = base.Pkgbase()
+			mux.Unlock()
 			return err
 		}
 
-		isExplicit := false
-		for _, b := range base {
-			isExplicit = isExplicit || ds.Explicit.get(b.Name)
-		}
-		if config.ReBuild == "no" || (config.ReBuild == "yes" && !isExplicit) {
-			for _, split := range base {
-				pkgdest, ok := pkgdests[split.Name]
-				if !ok {
-					return fmt.Errorf("Could not find PKGDEST for: %s", split.Name)
-				}
+		sess.setInstalled(base.Pkgbase())
+		trans.addBase(base, result.version, result.pkgdests, asdeps)
+		return nil
+	}
 
-				_, err := os.Stat(pkgdest)
-				if os.IsNotExist(err) {
-					built = false
-				} else if err != nil {
-					return err
-				}
-			}
-		} else {
-			built = false
+	err = scheduleBuilds(ds.Aur, config.BuildJobs, build, install)
+	if err != nil && failed != "" {
+		sess.setFailed(failed)
+	}
+
+	// Record whatever made it in, even if a later base failed.
+	if len(trans.Bases) > 0 {
+		if herr := saveTransaction(trans); herr != nil {
+			fmt.Fprintln(os.Stderr, herr)
 		}
+	}
 
-		if cmdArgs.existsArg("needed") {
-			installed := true
-			for _, split := range base {
-				if alpmpkg, err := ds.LocalDb.PkgByName(split.Name); err != nil || alpmpkg.Version() != version {
-					installed = false
-				}
-			}
+	return err
+}
 
-			if installed {
-				show(passToMakepkg(dir, "-c", "--nobuild", "--noextract", "--ignorearch"))
-				fmt.Println(cyan(pkg+"-"+version) + bold(" is up to date -- skipping"))
+// builtDependencies returns the package files of the already built bases that
+// base i depends on, directly or through another base.
+func builtDependencies(i int, deps map[int][]int, built map[int]map[string]string) []string {
+	files := make([]string, 0)
+	seen := make(map[int]bool)
+
+	var walk func(int)
+	walk = func(i int) {
+		for _, dep := range deps[i] {
+			if seen[dep] {
 				continue
 			}
-		}
+			seen[dep] = true
+			walk(dep)
 
-		if built {
-			show(passToMakepkg(dir, "-c", "--nobuild", "--noextract", "--ignorearch"))
-			fmt.Println(bold(yellow(arrow)),
-				cyan(pkg+"-"+version)+bold(" already made -- skipping build"))
-		} else {
-			args := []string{"-cf", "--noconfirm", "--noextract", "--noprepare", "--holdver"}
-
-			if incompatible.get(pkg) {
-				args = append(args, "--ignorearch")
+			names := make([]string, 0, len(built[dep]))
+			for name := range built[dep] {
+				names = append(names, name)
 			}
+			sort.Strings(names)
 
-			err := show(passToMakepkg(dir, args...))
-			if err != nil {
-				return fmt.Errorf("Error making: %s", base.String())
+			for _, name := range names {
+				if _, err := os.Stat(built[dep][name]); err == nil {
+					files = append(files, built[dep][name])
+				}
 			}
 		}
+	}
 
-		arguments := parser.copy()
-		arguments.clearTargets()
-		arguments.op = "U"
-		arguments.delArg("confirm")
-		arguments.delArg("noconfirm")
-		arguments.delArg("c", "clean")
-		arguments.delArg("q", "quiet")
-		arguments.delArg("q", "quiet")
-		arguments.delArg("y", "refresh")
-		arguments.delArg("u", "sysupgrade")
-		arguments.delArg("w", "downloadonly")
+	walk(i)
+	return files
+}
 
-		oldConfirm := config.NoConfirm
+// buildPkgbuild builds a single base writing all output to out. The returned
+// bool is true when the base does not need to be installed.
+func buildPkgbuild(ds *depSolver, base Base, b builder, deps []string, incompatible stringSet, localVersions map[string]string, needed bool, out io.Writer) (map[string]string, string, bool, error) {
+	pkg := base.Pkgbase()
+	dir := filepath.Join(config.BuildDir, pkg)
+	built := true
 
-		//conflicts have been checked so answer y for them
-		if config.UseAsk {
-			ask, _ := strconv.Atoi(cmdArgs.globals["ask"])
-			uask := alpm.QuestionType(ask) | alpm.QuestionTypeConflictPkg
-			cmdArgs.globals["ask"] = fmt.Sprint(uask)
-		} else {
-			conflict := false
-			for _, split := range base {
-				if _, ok := conflicts[split.Name]; ok {
-					conflict = true
-				}
-			}
+	args := []string{"--nobuild", "-fC"}
 
-			if !conflict {
-				config.NoConfirm = true
-			}
-		}
+	// Dependencies are installed in the chroot, not on the host.
+	if config.Chroot {
+		args = append(args, "--nodeps")
+	}
 
-		depArguments := makeArguments()
-		depArguments.addArg("D", "asdeps")
-		expArguments := makeArguments()
-		expArguments.addArg("D", "asexplicit")
+	if incompatible.get(pkg) {
+		args = append(args, "--ignorearch")
+	}
 
-		//remotenames: names of all non repo packages on the system
-		_, _, localNames, remoteNames, err := filterPackages()
-		if err != nil {
-			return err
-		}
+	//pkgver bump
+	err := showOutput(passToMakepkg(dir, args...), out)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("Error making: %s", base.String())
+	}
 
-		//cache as a stringset. maybe make it return a string set in the first
-		//place
-		remoteNamesCache := sliceToStringSet(remoteNames)
-		localNamesCache := sliceToStringSet(localNames)
+	pkgdests, version, err := parsePackageList(dir)
+	if err != nil {
+		return nil, "", false, err
+	}
 
+	isExplicit := false
+	for _, b := range base {
+		isExplicit = isExplicit || ds.Explicit.get(b.Name)
+	}
+	if config.ReBuild == "no" || (config.ReBuild == "yes" && !isExplicit) {
 		for _, split := range base {
 			pkgdest, ok := pkgdests[split.Name]
 			if !ok {
-				return fmt.Errorf("Could not find PKGDEST for: %s", split.Name)
+				return nil, "", false, fmt.Errorf("Could not find PKGDEST for: %s", split.Name)
 			}
 
-			arguments.addTarget(pkgdest)
-			if !ds.Explicit.get(split.Name) && !localNamesCache.get(split.Name) && !remoteNamesCache.get(split.Name) {
-				depArguments.addTarget(split.Name)
+			_, err := os.Stat(pkgdest)
+			if os.IsNotExist(err) {
+				built = false
+			} else if err != nil {
+				return nil, "", false, err
 			}
+		}
+	} else {
+		built = false
+	}
 
-			if ds.Explicit.get(split.Name) {
-				if parser.existsArg("asdeps", "asdep") {
-					depArguments.addTarget(split.Name)
-				} else if parser.existsArg("asexplicit", "asexp") {
-					expArguments.addTarget(split.Name)
-				}
+	if needed {
+		installed := true
+		for _, split := range base {
+			if localVersion, ok := localVersions[split.Name]; !ok || localVersion != version {
+				installed = false
 			}
 		}
 
-		err = show(passToPacman(arguments))
+		if installed {
+			showOutput(passToMakepkg(dir, "-c", "--nobuild", "--noextract", "--ignorearch"), out)
+			fmt.Fprintln(out, cyan(pkg+"-"+version)+bold(" is up to date -- skipping"))
+			return pkgdests, version, true, nil
+		}
+	}
+
+	if built {
+		showOutput(passToMakepkg(dir, "-c", "--nobuild", "--noextract", "--ignorearch"), out)
+		fmt.Fprintln(out, bold(yellow(arrow)),
+			cyan(pkg+"-"+version)+bold(" already made -- skipping build"))
+	} else {
+		err := b.build(dir, incompatible.get(pkg), deps, out)
 		if err != nil {
-			return err
+			return nil, "", false, fmt.Errorf("Error making: %s", base.String())
 		}
+	}
 
-		var mux sync.Mutex
-		var wg sync.WaitGroup
-		for _, pkg := range base {
-			wg.Add(1)
-			go updateVCSData(pkg.Name, srcinfo.Source, &mux, &wg)
+	return pkgdests, version, false, nil
+}
+
+// installPkgbuild installs the packages built from base and sets their install
+// reason. The packages that were marked as dependencies are returned.
+func installPkgbuild(ds *depSolver, base Base, srcinfo *gosrc.Srcinfo, pkgdests map[string]string, repo *localRepo, parser *arguments, conflicts mapStringSet) (stringSet, error) {
+	arguments := parser.copy()
+	arguments.clearTargets()
+	arguments.op = "U"
+	if repo != nil {
+		arguments.op = "S"
+	}
+	arguments.delArg("confirm")
+	arguments.delArg("noconfirm")
+	arguments.delArg("c", "clean")
+	arguments.delArg("q", "quiet")
+	arguments.delArg("q", "quiet")
+	arguments.delArg("y", "refresh")
+	arguments.delArg("u", "sysupgrade")
+	arguments.delArg("w", "downloadonly")
+
+	// Other bases may be building while this one is installed, so work on
+	// copies rather than changing cmdArgs and config.
+	globals := cmdArgs.copy()
+	noConfirm := config.NoConfirm
+
+	//conflicts have been checked so answer y for them
+	if config.UseAsk {
+		ask, _ := strconv.Atoi(globals.globals["ask"])
+		uask := alpm.QuestionType(ask) | alpm.QuestionTypeConflictPkg
+		globals.globals["ask"] = fmt.Sprint(uask)
+	} else {
+		conflict := false
+		for _, split := range base {
+			if _, ok := conflicts[split.Name]; ok {
+				conflict = true
+			}
+		}
+
+		if !conflict {
+			noConfirm = true
 		}
+	}
 
-		wg.Wait()
+	depArguments := makeArguments()
+	depArguments.addArg("D", "asdeps")
+	expArguments := makeArguments()
+	expArguments.addArg("D", "asexplicit")
 
-		err = saveVCSInfo()
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
+	//remotenames: names of all non repo packages on the system
+	_, _, localNames, remoteNames, err := filterPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	//cache as a stringset. maybe make it return a string set in the first
+	//place
+	remoteNamesCache := sliceToStringSet(remoteNames)
+	localNamesCache := sliceToStringSet(localNames)
+
+	files := make([]string, 0, len(base))
+	for _, split := range base {
+		pkgdest, ok := pkgdests[split.Name]
+		if !ok {
+			return nil, fmt.Errorf("Could not find PKGDEST for: %s", split.Name)
 		}
 
-		if len(depArguments.targets) > 0 {
-			_, stderr, err := capture(passToPacman(depArguments))
-			if err != nil {
-				return fmt.Errorf("%s%s", stderr, err)
+		if repo != nil {
+			files = append(files, pkgdest)
+			arguments.addTarget(repo.Name + "/" + split.Name)
+		} else {
+			arguments.addTarget(pkgdest)
+		}
+
+		if !ds.Explicit.get(split.Name) && !localNamesCache.get(split.Name) && !remoteNamesCache.get(split.Name) {
+			depArguments.addTarget(split.Name)
+		}
+
+		if ds.Explicit.get(split.Name) {
+			if parser.existsArg("asdeps", "asdep") {
+				depArguments.addTarget(split.Name)
+			} else if parser.existsArg("asexplicit", "asexp") {
+				expArguments.addTarget(split.Name)
 			}
 		}
-		config.NoConfirm = oldConfirm
 	}
 
-	return nil
+	if repo != nil {
+		if err = repo.add(files); err != nil {
+			return nil, err
+		}
+	}
+
+	err = show(passToPacmanWith(arguments, globals, noConfirm))
+	if err != nil {
+		return nil, err
+	}
+
+	var mux sync.Mutex
+	var wg sync.WaitGroup
+	for _, pkg := range base {
+		wg.Add(1)
+		go updateVCSData(pkg.Name, srcinfo.Source, &mux, &wg)
+	}
+
+	wg.Wait()
+
+	err = saveVCSInfo()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	if len(depArguments.targets) > 0 {
+		_, stderr, err := capture(passToPacmanWith(depArguments, globals, noConfirm))
+		if err != nil {
+			return nil, fmt.Errorf("%s%s", stderr, err)
+		}
+	}
+
+	return sliceToStringSet(depArguments.targets), nil
 }
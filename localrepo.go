@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	pacmanconf "github.com/Morganamilo/go-pacmanconf"
+)
+
+// localRepo is a pacman repo yay publishes built AUR packages to.
+type localRepo struct {
+	Name string
+	Dir  string
+	repo pacmanconf.Repository
+}
+
+// getLocalRepo looks up the repo named by config.LocalRepo in pacman.conf. It
+// returns nil when no local repo is configured.
+func getLocalRepo() (*localRepo, error) {
+	if config.LocalRepo == "" {
+		return nil, nil
+	}
+
+	for _, repo := range pacmanConf.Repos {
+		if repo.Name != config.LocalRepo {
+			continue
+		}
+
+		for _, server := range repo.Servers {
+			if strings.HasPrefix(server, "file://") {
+				return &localRepo{repo.Name, strings.TrimPrefix(server, "file://"), repo}, nil
+			}
+		}
+
+		return nil, fmt.Errorf("local repo [%s] has no file:// server in %s", repo.Name, config.PacmanConf)
+	}
+
+	return nil, fmt.Errorf("local repo [%s] not found in %s", config.LocalRepo, config.PacmanConf)
+}
+
+func (r *localRepo) dbPath() string {
+	return filepath.Join(r.Dir, r.Name+".db.tar.gz")
+}
+
+// add copies the package files into the repo directory, adds them to its
+// database and refreshes pacman's copy of the database.
+func (r *localRepo) add(files []string) error {
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return err
+	}
+
+	copied := make([]string, 0, len(files))
+	for _, file := range files {
+		dest := filepath.Join(r.Dir, filepath.Base(file))
+		copied = append(copied, dest)
+
+		// PKGDEST may already point at the repo.
+		if filepath.Clean(file) == filepath.Clean(dest) {
+			continue
+		}
+
+		if err := copyFile(file, dest); err != nil {
+			return fmt.Errorf("error copying %s to %s: %s", file, r.Dir, err)
+		}
+	}
+
+	args := append([]string{r.dbPath()}, copied...)
+	if err := show(exec.Command("repo-add", args...)); err != nil {
+		return fmt.Errorf("error adding packages to %s", r.dbPath())
+	}
+
+	return r.refresh()
+}
+
+// refresh syncs only this repo's database so the rest of the system does not
+// end up with refreshed databases but no upgrade.
+func (r *localRepo) refresh() error {
+	conf, err := ioutil.TempFile("", "yay-localrepo")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(conf.Name())
+
+	_, err = conf.WriteString(r.pacmanConf())
+	conf.Close()
+	if err != nil {
+		return err
+	}
+
+	waitLock()
+	err = show(exec.Command("sudo", config.PacmanBin, "--config", conf.Name(), "-Sy"))
+	if err != nil {
+		return fmt.Errorf("error refreshing %s", r.Name)
+	}
+
+	return nil
+}
+
+// pacmanConf returns a pacman.conf defining only this repo, using the
+// database path and signature levels of the real one.
+func (r *localRepo) pacmanConf() string {
+	str := "[options]\n"
+	str += "DBPath = " + pacmanConf.DBPath + "\n"
+	for _, sigLevel := range pacmanConf.SigLevel {
+		str += "SigLevel = " + sigLevel + "\n"
+	}
+	str += "[" + r.Name + "]\n"
+	for _, sigLevel := range r.repo.SigLevel {
+		str += "SigLevel = " + sigLevel + "\n"
+	}
+	for _, server := range r.repo.Servers {
+		str += "Server = " + server + "\n"
+	}
+
+	return str
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pacmanconf "github.com/Morganamilo/go-pacmanconf"
+)
+
+func TestGetLocalRepo(t *testing.T) {
+	oldConfig, oldPacmanConf := config, pacmanConf
+	defer func() { config, pacmanConf = oldConfig, oldPacmanConf }()
+
+	config = defaultSettings()
+	pacmanConf = &pacmanconf.Config{
+		DBPath:   "/var/lib/pacman/",
+		SigLevel: []string{"Required", "DatabaseOptional"},
+		Repos: []pacmanconf.Repository{
+			{Name: "core", Servers: []string{"https://mirror.example.com/core/os/x86_64"}},
+			{Name: "aur", SigLevel: []string{"Optional", "TrustAll"}, Servers: []string{"https://example.com/aur", "file:///srv/repo/aur"}},
+		},
+	}
+
+	testCases := []struct {
+		name string
+		dir  string
+		ok   bool
+	}{
+		{"", "", true},
+		{"aur", "/srv/repo/aur", true},
+		{"core", "", false},
+		{"missing", "", false},
+	}
+
+	for _, tc := range testCases {
+		config.LocalRepo = tc.name
+		repo, err := getLocalRepo()
+		if (err == nil) != tc.ok {
+			t.Fatalf("%s: unexpected error %v", tc.name, err)
+		}
+
+		if tc.dir == "" {
+			if repo != nil {
+				t.Fatalf("%s: expected no repo, got %+v", tc.name, repo)
+			}
+			continue
+		}
+
+		if repo == nil || repo.Name != tc.name || repo.Dir != tc.dir {
+			t.Fatalf("%s: unexpected repo %+v", tc.name, repo)
+		}
+		if db := repo.dbPath(); db != "/srv/repo/aur/aur.db.tar.gz" {
+			t.Fatalf("%s: unexpected database path %s", tc.name, db)
+		}
+
+		expected := "[options]\n" +
+			"DBPath = /var/lib/pacman/\n" +
+			"SigLevel = Required\n" +
+			"SigLevel = DatabaseOptional\n" +
+			"[aur]\n" +
+			"SigLevel = Optional\n" +
+			"SigLevel = TrustAll\n" +
+			"Server = https://example.com/aur\n" +
+			"Server = file:///srv/repo/aur\n"
+		if conf := repo.pacmanConf(); conf != expected {
+			t.Fatalf("%s: expected pacman.conf\n%s\ngot\n%s", tc.name, expected, conf)
+		}
+	}
+}
+
+func TestCopyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yay-localrepo")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "foo-1.0-1-x86_64.pkg.tar.xz")
+	dest := filepath.Join(dir, "repo.pkg.tar.xz")
+	ioutil.WriteFile(src, []byte("package"), 0644)
+
+	if err = copyFile(src, dest); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if content, _ := ioutil.ReadFile(dest); string(content) != "package" {
+		t.Fatalf("Unexpected content %q", content)
+	}
+
+	if err = copyFile(filepath.Join(dir, "missing"), dest); err == nil {
+		t.Fatalf("Expected an error for a missing file")
+	}
+}
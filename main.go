@@ -29,7 +29,12 @@ func setPaths() error {
 	}
 
 	configFile = filepath.Join(configHome, configFileName)
+	pinsFile = filepath.Join(configHome, pinsFileName)
+	patchesDir = filepath.Join(configHome, "patches")
 	vcsFile = filepath.Join(cacheHome, vcsFileName)
+	historyFile = filepath.Join(cacheHome, historyFileName)
+	sessionFile = filepath.Join(cacheHome, sessionFileName)
+	aurCacheFile = filepath.Join(cacheHome, aurCacheFileName)
 
 	return nil
 }
@@ -216,6 +221,7 @@ func main() {
 		config.saveConfig()
 	}
 	config.expandEnv()
+	exitOnError(initHTTPClient())
 	exitOnError(initBuildDir())
 	exitOnError(initVCS())
 	exitOnError(initAlpm())
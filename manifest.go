@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	alpm "github.com/jguer/go-alpm"
+)
+
+// manifestEntry is a package the manifest wants explicitly installed.
+type manifestEntry struct {
+	DB      string
+	Name    string
+	Version string
+}
+
+// target returns the entry as a target for install, keeping the pin so that
+// exactly that version is installed.
+func (e manifestEntry) target() string {
+	target := e.Name
+	if e.Version != "" {
+		target += "=" + e.Version
+	}
+	if e.DB != "" {
+		return e.DB + "/" + target
+	}
+	return target
+}
+
+// manifest describes the explicitly installed packages of a system.
+//
+// Each line holds one package, optionally prefixed with the db it should come
+// from and followed by =version to pin it:
+//
+//	core/bash
+//	aur/yay=8.2.0-1
+//
+// Lines starting with ! are glob patterns for installed packages the manifest
+// does not manage, # starts a comment.
+type manifest struct {
+	entries []manifestEntry
+	ignore  []string
+}
+
+func parseManifest(r io.Reader) (*manifest, error) {
+	m := &manifest{}
+	scanner := bufio.NewScanner(r)
+	n := 0
+
+	for scanner.Scan() {
+		n++
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "!") {
+			pattern := strings.TrimSpace(line[1:])
+			if _, err := filepath.Match(pattern, ""); err != nil || pattern == "" {
+				return nil, fmt.Errorf("line %d: invalid ignore pattern: %s", n, line)
+			}
+			m.ignore = append(m.ignore, pattern)
+			continue
+		}
+
+		if strings.ContainsAny(line, " \t<>") {
+			return nil, fmt.Errorf("line %d: invalid entry: %s", n, line)
+		}
+
+		var entry manifestEntry
+		if i := strings.Index(line, "/"); i != -1 {
+			entry.DB = line[:i]
+			line = line[i+1:]
+		}
+		if i := strings.Index(line, "="); i != -1 {
+			entry.Version = line[i+1:]
+			line = line[:i]
+		}
+		entry.Name = line
+
+		if entry.Name == "" {
+			return nil, fmt.Errorf("line %d: missing package name", n)
+		}
+
+		m.entries = append(m.entries, entry)
+	}
+
+	return m, scanner.Err()
+}
+
+func (m *manifest) ignored(name string) bool {
+	for _, pattern := range m.ignore {
+		if match, _ := filepath.Match(pattern, name); match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// manifestPlan is what it takes to bring the system in line with a manifest.
+type manifestPlan struct {
+	Install      []string
+	MarkExplicit []string
+	Extras       []string
+	Mismatch     []string
+}
+
+// planManifest diffs m against the installed packages. installed maps every
+// installed package to its version, explicit holds the explicitly installed
+// ones. Packages in the manifest installed as dependencies are marked as
+// explicitly installed so they are not removed with the other dependencies.
+func planManifest(m *manifest, installed map[string]string, explicit stringSet) manifestPlan {
+	var plan manifestPlan
+	wanted := make(stringSet)
+
+	for _, entry := range m.entries {
+		wanted.set(entry.Name)
+		version, ok := installed[entry.Name]
+
+		if !ok {
+			plan.Install = append(plan.Install, entry.target())
+			continue
+		}
+
+		if !explicit.get(entry.Name) {
+			plan.MarkExplicit = append(plan.MarkExplicit, entry.Name)
+		}
+		if entry.Version != "" && entry.Version != version {
+			plan.Mismatch = append(plan.Mismatch, fmt.Sprintf("%s (pinned %s, installed %s)", entry.Name, entry.Version, version))
+		}
+	}
+
+	for name := range explicit {
+		if !wanted.get(name) && !m.ignored(name) {
+			plan.Extras = append(plan.Extras, name)
+		}
+	}
+
+	sort.Strings(plan.Extras)
+	return plan
+}
+
+// syncManifest installs the packages from the manifest at path that are
+// missing and marks explicitly installed packages not in it as dependencies,
+// or removes them with --removeextras.
+func syncManifest(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	m, err := parseManifest(file)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	local, remote, _, _, err := filterPackages()
+	if err != nil {
+		return err
+	}
+
+	installed := make(map[string]string)
+	explicit := make(stringSet)
+	foreign := make(stringSet)
+	for _, pkgs := range [][]alpm.Package{local, remote} {
+		for _, pkg := range pkgs {
+			installed[pkg.Name()] = pkg.Version()
+			if pkg.Reason() == alpm.PkgReasonExplicit {
+				explicit.set(pkg.Name())
+			}
+		}
+	}
+	for _, pkg := range remote {
+		foreign.set(pkg.Name())
+	}
+
+	plan := planManifest(m, installed, explicit)
+	removeExtras := cmdArgs.existsArg("removeextras")
+	printManifestPlan(plan, foreign, removeExtras)
+
+	if len(plan.Install) == 0 && len(plan.MarkExplicit) == 0 && len(plan.Extras) == 0 {
+		fmt.Println(" there is nothing to do")
+		return nil
+	}
+
+	if cmdArgs.existsArg("dryrun") {
+		return nil
+	}
+
+	if len(plan.Install) > 0 {
+		arguments := makeArguments()
+		arguments.addTarget(plan.Install...)
+
+		if config.SudoLoop {
+			sudoLoopBackground()
+		}
+
+		if err = install(arguments); err != nil {
+			return err
+		}
+	}
+
+	if len(plan.MarkExplicit) > 0 {
+		arguments := makeArguments()
+		arguments.addArg("D", "asexplicit")
+		arguments.addTarget(plan.MarkExplicit...)
+		if err = show(passToPacman(arguments)); err != nil {
+			return err
+		}
+	}
+
+	if len(plan.Extras) == 0 {
+		return nil
+	}
+
+	if removeExtras {
+		return cleanRemove(plan.Extras)
+	}
+
+	arguments := makeArguments()
+	arguments.addArg("D", "asdeps")
+	arguments.addTarget(plan.Extras...)
+	return show(passToPacman(arguments))
+}
+
+func printManifestPlan(plan manifestPlan, foreign stringSet, removeExtras bool) {
+	if len(plan.Install) > 0 {
+		fmt.Println(bold(cyan("::") + bold(" Packages to install:")))
+		fmt.Println("    " + strings.Join(plan.Install, "  "))
+	}
+
+	if len(plan.MarkExplicit) > 0 {
+		fmt.Println(bold(cyan("::") + bold(" Packages to mark as explicitly installed:")))
+		fmt.Println("    " + strings.Join(plan.MarkExplicit, "  "))
+	}
+
+	if len(plan.Extras) > 0 {
+		action := " Packages to mark as dependencies:"
+		if removeExtras {
+			action = " Packages to remove:"
+		}
+
+		extras := make([]string, 0, len(plan.Extras))
+		for _, name := range plan.Extras {
+			if foreign.get(name) {
+				name += " " + magenta("(foreign)")
+			}
+			extras = append(extras, name)
+		}
+
+		fmt.Println(bold(cyan("::") + bold(action)))
+		fmt.Println("    " + strings.Join(extras, "  "))
+	}
+
+	if len(plan.Mismatch) > 0 {
+		fmt.Println(bold(yellow(arrow)) + bold(" Installed versions differ from the pinned versions:"))
+		for _, str := range plan.Mismatch {
+			fmt.Println("    " + str)
+		}
+	}
+}
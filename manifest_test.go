@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testManifest = `# base system
+core/bash
+linux=5.0.1-1 # held back
+
+aur/yay=8.2.0-1
+!python-*
+`
+
+func TestParseManifest(t *testing.T) {
+	m, err := parseManifest(strings.NewReader(testManifest))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := []manifestEntry{
+		{"core", "bash", ""},
+		{"", "linux", "5.0.1-1"},
+		{"aur", "yay", "8.2.0-1"},
+	}
+	if !reflect.DeepEqual(m.entries, expected) {
+		t.Fatalf("expected %v, got %v", expected, m.entries)
+	}
+
+	if !reflect.DeepEqual(m.ignore, []string{"python-*"}) {
+		t.Fatalf("expected ignore [python-*], got %v", m.ignore)
+	}
+
+	targets := make([]string, 0, len(m.entries))
+	for _, entry := range m.entries {
+		targets = append(targets, entry.target())
+	}
+	if expected := []string{"core/bash", "linux=5.0.1-1", "aur/yay=8.2.0-1"}; !reflect.DeepEqual(targets, expected) {
+		t.Fatalf("expected targets %v, got %v", expected, targets)
+	}
+
+	for _, invalid := range []string{"foo bar", "foo<2.0", "core/", "![", "!"} {
+		if _, err = parseManifest(strings.NewReader(invalid)); err == nil {
+			t.Fatalf("%s: expected an error", invalid)
+		}
+	}
+}
+
+func TestPlanManifest(t *testing.T) {
+	m, err := parseManifest(strings.NewReader(testManifest))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	installed := map[string]string{
+		"bash":          "5.0.0-1",
+		"linux":         "5.0.2-1",
+		"python-foo":    "1.0-1",
+		"vim":           "8.1-1",
+		"glibc":         "2.29-1",
+		"python-foodep": "1.0-1",
+	}
+	explicit := sliceToStringSet([]string{"bash", "linux", "python-foo", "vim"})
+
+	plan := planManifest(m, installed, explicit)
+
+	if expected := []string{"aur/yay=8.2.0-1"}; !reflect.DeepEqual(plan.Install, expected) {
+		t.Fatalf("expected install %v, got %v", expected, plan.Install)
+	}
+	if len(plan.MarkExplicit) != 0 {
+		t.Fatalf("expected nothing to mark as explicit, got %v", plan.MarkExplicit)
+	}
+	if expected := []string{"vim"}; !reflect.DeepEqual(plan.Extras, expected) {
+		t.Fatalf("expected extras %v, got %v", expected, plan.Extras)
+	}
+
+	// Installed as a dependency of something else
+	installed["yay"] = "8.2.0-1"
+	plan = planManifest(m, installed, explicit)
+	if len(plan.Install) != 0 {
+		t.Fatalf("expected nothing to install, got %v", plan.Install)
+	}
+	if expected := []string{"yay"}; !reflect.DeepEqual(plan.MarkExplicit, expected) {
+		t.Fatalf("expected to mark %v as explicit, got %v", expected, plan.MarkExplicit)
+	}
+	if expected := []string{"vim"}; !reflect.DeepEqual(plan.Extras, expected) {
+		t.Fatalf("expected extras %v, got %v", expected, plan.Extras)
+	}
+	if expected := []string{"linux (pinned 5.0.1-1, installed 5.0.2-1)"}; !reflect.DeepEqual(plan.Mismatch, expected) {
+		t.Fatalf("expected mismatch %v, got %v", expected, plan.Mismatch)
+	}
+}
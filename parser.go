@@ -146,7 +146,7 @@ func (parser *arguments) needRoot() bool {
 		if parser.existsArg("y", "refresh") {
 			return true
 		}
-		if parser.existsArg("p", "print", "print-format") {
+		if parser.existsArg("p", "print", "print-format", "plan") {
 			return false
 		}
 		if parser.existsArg("s", "search") {
@@ -391,6 +391,8 @@ func isArg(arg string) bool {
 	case "needed":
 	case "overwrite":
 	case "f", "force":
+	case "log":
+	case "commit":
 	case "c", "changelog":
 	case "deps":
 	case "e", "explicit":
@@ -418,9 +420,19 @@ func isArg(arg string) bool {
 	case "machinereadable":
 	//yay options
 	case "aururl":
+	case "absurl":
 	case "save":
 	case "afterclean", "cleanafter":
 	case "noafterclean", "nocleanafter":
+	case "chroot":
+	case "nochroot":
+	case "localrepo":
+	case "nolocalrepo":
+	case "srcdest":
+	case "nosrcdest":
+	case "reviewcmds":
+	case "noreviewcmds":
+	case "offline":
 	case "devel":
 	case "nodevel":
 	case "timeupdate":
@@ -460,6 +472,13 @@ func isArg(arg string) bool {
 	case "git":
 	case "gpg":
 	case "requestsplitn":
+	case "buildjobs":
+	case "downloadjobs":
+	case "httptimeout":
+	case "httpproxy":
+	case "cafile":
+	case "useragent":
+	case "maxconnections":
 	case "sudoloop":
 	case "nosudoloop":
 	case "provides":
@@ -485,9 +504,27 @@ func isArg(arg string) bool {
 	case "askremovemake":
 	case "complete":
 	case "stats":
+	case "tree":
+	case "dot":
+	case "rdeps":
+	case "whydepends":
 	case "news":
 	case "gendb":
+	case "history":
+	case "rollback":
+	case "sync-manifest":
+	case "pin":
+	case "unpin":
+	case "reason":
+	case "removeextras":
+	case "dryrun":
+	case "plan":
+	case "srcmaxage":
+	case "srcmaxsize":
+	case "resume":
+	case "skipfailed":
 	case "currentconfig":
+	case "json":
 	default:
 		return false
 	}
@@ -499,12 +536,32 @@ func handleConfig(option, value string) bool {
 	switch option {
 	case "aururl":
 		config.AURURL = value
+	case "absurl":
+		config.ABSURL = value
 	case "save":
 		shouldSaveConfig = true
 	case "afterclean", "cleanafter":
 		config.CleanAfter = true
 	case "noafterclean", "nocleanafter":
 		config.CleanAfter = false
+	case "chroot":
+		config.Chroot = true
+	case "nochroot":
+		config.Chroot = false
+	case "localrepo":
+		config.LocalRepo = value
+	case "nolocalrepo":
+		config.LocalRepo = ""
+	case "srcdest":
+		config.SrcDest = value
+	case "nosrcdest":
+		config.SrcDest = ""
+	case "reviewcmds":
+		config.ReviewCmds = value
+	case "noreviewcmds":
+		config.ReviewCmds = ""
+	case "offline":
+		config.Offline = true
 	case "devel":
 		config.Devel = true
 	case "nodevel":
@@ -593,6 +650,32 @@ func handleConfig(option, value string) bool {
 		if err == nil && n > 0 {
 			config.RequestSplitN = n
 		}
+	case "buildjobs":
+		n, err := strconv.Atoi(value)
+		if err == nil && n > 0 {
+			config.BuildJobs = n
+		}
+	case "downloadjobs":
+		n, err := strconv.Atoi(value)
+		if err == nil && n > 0 {
+			config.DownloadJobs = n
+		}
+	case "httptimeout":
+		n, err := strconv.Atoi(value)
+		if err == nil && n >= 0 {
+			config.HTTPTimeout = n
+		}
+	case "httpproxy":
+		config.HTTPProxy = value
+	case "cafile":
+		config.CAFile = value
+	case "useragent":
+		config.UserAgent = value
+	case "maxconnections":
+		n, err := strconv.Atoi(value)
+		if err == nil && n > 0 {
+			config.MaxConnections = n
+		}
 	case "sudoloop":
 		config.SudoLoop = true
 	case "nosudoloop":
@@ -709,6 +792,7 @@ func hasParam(arg string) bool {
 	case "color":
 	//yay params
 	case "aururl":
+	case "absurl":
 	case "mflags":
 	case "gpgflags":
 	case "gitflags":
@@ -722,12 +806,28 @@ func hasParam(arg string) bool {
 	case "git":
 	case "gpg":
 	case "requestsplitn":
+	case "buildjobs":
+	case "downloadjobs":
+	case "httptimeout":
+	case "httpproxy":
+	case "cafile":
+	case "useragent":
+	case "maxconnections":
+	case "localrepo":
+	case "srcdest":
+	case "srcmaxage":
+	case "srcmaxsize":
+	case "reviewcmds":
+	case "reason":
+	case "commit":
 	case "answerclean":
 	case "answerdiff":
 	case "answeredit":
 	case "answerupgrade":
 	case "completioninterval":
 	case "sortby":
+	case "rollback":
+	case "sync-manifest":
 	default:
 		return false
 	}
@@ -879,6 +979,7 @@ func (parser *arguments) extractYayOptions() {
 
 	rpc.AURURL = strings.TrimRight(config.AURURL, "/") + "/rpc.php?"
 	config.AURURL = strings.TrimRight(config.AURURL, "/")
+	selectAURBackend()
 }
 
 //parses input for number menus split by spaces or commas
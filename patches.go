@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// basePatches returns the patches kept for pkgbase, in the order they are
+// applied.
+func basePatches(pkgbase string) ([]string, error) {
+	return filepath.Glob(filepath.Join(patchesDir, pkgbase, "*.patch"))
+}
+
+// patchPkgbuilds applies the local patches of each base on top of the
+// merged PKGBUILD and regenerates the .SRCINFO of every base it patched.
+// A patch that does not apply can be replaced by editing the PKGBUILD,
+// otherwise that base fails. Every base is tried before the errors are
+// returned.
+func patchPkgbuilds(bases []Base) error {
+	var errs MultiError
+
+	for _, base := range bases {
+		errs.Add(patchPkgbuild(base.Pkgbase()))
+	}
+
+	return errs.Return()
+}
+
+func patchPkgbuild(pkg string) error {
+	patches, err := basePatches(pkg)
+	if err != nil {
+		return err
+	}
+	if len(patches) == 0 {
+		return nil
+	}
+
+	dir := filepath.Join(config.BuildDir, pkg)
+	for k, patch := range patches {
+		str := bold(cyan("::") + " Applying patch (%d/%d): %s\n")
+		fmt.Printf(str, k+1, len(patches), cyan(pkg+"/"+filepath.Base(patch)))
+
+		applied, err := applyPatch(dir, patch)
+		if err == nil {
+			if !applied {
+				fmt.Println(bold(yellow(smallArrow)), filepath.Base(patch), bold("is already applied -- skipping"))
+			}
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "%s %s does not apply to %s:\n%s\n", red(bold(smallArrow)), patch, cyan(pkg), err)
+		if !continueTask(bold(green("Edit PKGBUILD of "+pkg+" instead?")), false) {
+			return fmt.Errorf("%s: %s does not apply, refusing to build without it", pkg, filepath.Base(patch))
+		}
+
+		if err = editPkgbuild(dir); err != nil {
+			return err
+		}
+	}
+
+	return writeSrcinfo(dir)
+}
+
+// applyPatch applies patch to the files in dir. It returns false if the patch
+// was already applied, as happens to bases not kept in git.
+func applyPatch(dir string, patch string) (bool, error) {
+	if _, _, err := capture(passToGit(dir, "apply", "--check", "-R", patch)); err == nil {
+		return false, nil
+	}
+
+	_, stderr, err := capture(passToGit(dir, "apply", patch))
+	if err != nil {
+		return false, fmt.Errorf("%s", stderr)
+	}
+
+	return true, nil
+}
+
+func editPkgbuild(dir string) error {
+	editor, editorArgs := editor()
+	editorArgs = append(editorArgs, filepath.Join(dir, "PKGBUILD"))
+	editcmd := exec.Command(editor, editorArgs...)
+	editcmd.Stdin, editcmd.Stdout, editcmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := editcmd.Run(); err != nil {
+		return fmt.Errorf("Editor did not exit successfully, Aborting: %s", err)
+	}
+
+	return nil
+}
+
+// writeSrcinfo regenerates the .SRCINFO in dir from its PKGBUILD.
+func writeSrcinfo(dir string) error {
+	stdout, stderr, err := capture(passToMakepkg(dir, "--printsrcinfo"))
+	if err != nil {
+		return fmt.Errorf("error generating .SRCINFO for %s: %s", filepath.Base(dir), stderr)
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, ".SRCINFO"), []byte(stdout), 0644)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+const testPatch = `--- a/PKGBUILD
++++ b/PKGBUILD
+@@ -1,2 +1,2 @@
+ pkgname=foo
+-pkgver=1.0
++pkgver=1.1
+`
+
+func TestApplyPatch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "yay-patch")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldConfig := config
+	defer func() { config = oldConfig }()
+	config = defaultSettings()
+
+	pkgbuild := filepath.Join(dir, "PKGBUILD")
+	patch := filepath.Join(dir, "version.patch")
+	ioutil.WriteFile(pkgbuild, []byte("pkgname=foo\npkgver=1.0\n"), 0644)
+	ioutil.WriteFile(patch, []byte(testPatch), 0644)
+
+	if applied, err := applyPatch(dir, patch); err != nil || !applied {
+		t.Fatalf("Expected the patch to apply, got %v %v", applied, err)
+	}
+	if content, _ := ioutil.ReadFile(pkgbuild); string(content) != "pkgname=foo\npkgver=1.1\n" {
+		t.Fatalf("Unexpected PKGBUILD %q", content)
+	}
+
+	// Applying it again is a no-op
+	if applied, err := applyPatch(dir, patch); err != nil || applied {
+		t.Fatalf("Expected the patch to be skipped, got %v %v", applied, err)
+	}
+
+	ioutil.WriteFile(pkgbuild, []byte("pkgname=foo\npkgver=2.0\n"), 0644)
+	if _, err := applyPatch(dir, patch); err == nil {
+		t.Fatalf("Expected a conflicting patch to fail")
+	}
+}
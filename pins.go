@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// pin holds an AUR package back from being upgraded to any version not
+// matching Constraint, for example "=1.2-1" or "<2.0".
+type pin struct {
+	Constraint string `json:"constraint"`
+	Reason     string `json:"reason"`
+	Date       int64  `json:"date"`
+}
+
+// pins maps package names to their pin.
+type pins map[string]pin
+
+// heldUpgrade is an upgrade that was not offered because of a pin.
+type heldUpgrade struct {
+	Name          string `json:"name"`
+	LocalVersion  string `json:"localVersion"`
+	RemoteVersion string `json:"remoteVersion"`
+	Constraint    string `json:"constraint"`
+	Reason        string `json:"reason"`
+}
+
+// splitConstraint splits a constraint into its operator and version. A bare
+// version is treated as an exact match.
+func splitConstraint(constraint string) (string, string) {
+	constraint = strings.TrimSpace(constraint)
+	for _, mod := range []string{"<=", ">=", "<", ">", "="} {
+		if strings.HasPrefix(constraint, mod) {
+			return mod, strings.TrimSpace(strings.TrimPrefix(constraint, mod))
+		}
+	}
+
+	return "=", constraint
+}
+
+// allows reports whether version may be upgraded to. Devel upgrades have no
+// version to check so they are always held.
+func (p pin) allows(version string, devel bool) bool {
+	if devel {
+		return false
+	}
+
+	mod, pinVersion := splitConstraint(p.Constraint)
+	return verSatisfies(version, mod, pinVersion)
+}
+
+// parsePinTarget splits a target such as "foo<2.0" into the package name and
+// constraint. The constraint is empty if the target is a bare name.
+func parsePinTarget(target string) (string, string) {
+	i := strings.IndexAny(target, "<>=")
+	if i == -1 {
+		return target, ""
+	}
+
+	return target[:i], target[i:]
+}
+
+func loadPins() (pins, error) {
+	p := make(pins)
+
+	pfile, err := os.Open(pinsFile)
+	if os.IsNotExist(err) {
+		return p, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to open pin file '%s': %s", pinsFile, err)
+	}
+	defer pfile.Close()
+
+	decoder := json.NewDecoder(pfile)
+	if err = decoder.Decode(&p); err != nil {
+		return nil, fmt.Errorf("Failed to read pin file '%s': %s", pinsFile, err)
+	}
+
+	return p, nil
+}
+
+func (p pins) save() error {
+	marshalledinfo, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		return err
+	}
+	in, err := os.OpenFile(pinsFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_, err = in.Write(marshalledinfo)
+	if err != nil {
+		return err
+	}
+	err = in.Sync()
+	return err
+}
+
+// hold splits upgrades into the ones allowed by the pins and the ones held
+// back.
+func (p pins) hold(upgrades upSlice) (upSlice, []heldUpgrade) {
+	allowed := make(upSlice, 0, len(upgrades))
+	held := make([]heldUpgrade, 0)
+
+	for _, up := range upgrades {
+		pin, ok := p[up.Name]
+		if !ok || pin.allows(up.RemoteVersion, up.Devel) {
+			allowed = append(allowed, up)
+			continue
+		}
+
+		held = append(held, heldUpgrade{up.Name, up.LocalVersion, up.RemoteVersion, pin.Constraint, pin.Reason})
+	}
+
+	return allowed, held
+}
+
+func printHeldUpgrades(held []heldUpgrade, out io.Writer) {
+	for _, up := range held {
+		left, right := getVersionDiff(up.LocalVersion, up.RemoteVersion)
+		reason := ""
+		if up.Reason != "" {
+			reason = ": " + up.Reason
+		}
+
+		fmt.Fprintf(out, "%s %s: holding package upgrade (%s => %s) pinned to %s%s\n",
+			yellow(bold(smallArrow)),
+			cyan(up.Name),
+			left, right,
+			up.Constraint,
+			reason,
+		)
+	}
+}
+
+// pinPackages pins each target. A target without a constraint is pinned to
+// its installed version.
+func pinPackages(targets []string, reason string) error {
+	p, err := loadPins()
+	if err != nil {
+		return err
+	}
+
+	localDb, err := alpmHandle.LocalDb()
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		name, constraint := parsePinTarget(target)
+		if constraint == "" {
+			pkg, err := localDb.PkgByName(name)
+			if err != nil {
+				return fmt.Errorf("%s is not installed, give a version to pin it to", name)
+			}
+			constraint = "=" + pkg.Version()
+		} else if _, version := splitConstraint(constraint); version == "" {
+			return fmt.Errorf("invalid pin '%s'", target)
+		}
+
+		p[name] = pin{constraint, reason, time.Now().Unix()}
+		fmt.Println(bold(cyan("::")), bold("Pinned"), cyan(name), bold("to"), constraint)
+	}
+
+	return p.save()
+}
+
+func unpinPackages(targets []string) error {
+	p, err := loadPins()
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		name, _ := parsePinTarget(target)
+		if _, ok := p[name]; !ok {
+			return fmt.Errorf("%s is not pinned", name)
+		}
+
+		delete(p, name)
+		fmt.Println(bold(cyan("::")), bold("Unpinned"), cyan(name))
+	}
+
+	return p.save()
+}
+
+func printPins() error {
+	p, err := loadPins()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pin := p[name]
+		fmt.Printf("%s %s %s %s\n", bold(name), green(pin.Constraint), formatTime(int(pin.Date)), pin.Reason)
+	}
+
+	return nil
+}
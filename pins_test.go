@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPinsHold(t *testing.T) {
+	p := pins{
+		"exact":   {Constraint: "=1.0-1", Reason: "breaks plugins"},
+		"bare":    {Constraint: "1.0-1"},
+		"below":   {Constraint: "<2.0"},
+		"devel":   {Constraint: "=r10.abc-1"},
+		"notheld": {Constraint: "<2.0"},
+	}
+
+	upgrades := upSlice{
+		{"exact", "aur", "1.0-1", "1.1-1", false},
+		{"bare", "aur", "1.0-1", "1.1-1", false},
+		{"below", "aur", "1.0-1", "1.5-1", false},
+		{"notheld", "aur", "1.0-1", "2.1-1", false},
+		{"devel", "devel", "r10.abc-1", "latest-commit", true},
+		{"free", "aur", "1.0-1", "3.0-1", false},
+	}
+
+	allowed, held := p.hold(upgrades)
+
+	var allowedNames, heldNames []string
+	for _, up := range allowed {
+		allowedNames = append(allowedNames, up.Name)
+	}
+	for _, up := range held {
+		heldNames = append(heldNames, up.Name)
+	}
+
+	if expected := []string{"below", "free"}; !reflect.DeepEqual(allowedNames, expected) {
+		t.Fatalf("expected %v to be allowed, got %v", expected, allowedNames)
+	}
+	if expected := []string{"exact", "bare", "notheld", "devel"}; !reflect.DeepEqual(heldNames, expected) {
+		t.Fatalf("expected %v to be held, got %v", expected, heldNames)
+	}
+	if held[0].Reason != "breaks plugins" || held[0].Constraint != "=1.0-1" {
+		t.Fatalf("expected the pin to be kept with the held upgrade, got %+v", held[0])
+	}
+}
+
+func TestParsePinTarget(t *testing.T) {
+	testCases := []struct {
+		target     string
+		name       string
+		constraint string
+	}{
+		{"foo", "foo", ""},
+		{"foo<2.0", "foo", "<2.0"},
+		{"foo>=1:1.0-2", "foo", ">=1:1.0-2"},
+		{"foo=1.0", "foo", "=1.0"},
+	}
+
+	for _, tc := range testCases {
+		name, constraint := parsePinTarget(tc.target)
+		if name != tc.name || constraint != tc.constraint {
+			t.Fatalf("%s: expected %s %s, got %s %s", tc.target, tc.name, tc.constraint, name, constraint)
+		}
+	}
+}
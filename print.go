@@ -3,10 +3,10 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"strconv"
 	"strings"
@@ -294,49 +294,70 @@ func localStatistics() error {
 	return nil
 }
 
-//TODO: Make it less hacky
-func printNumberOfUpdates() error {
-	//todo
+// upgradeList is the structure printed when the upgrade list is requested
+// with --json.
+type upgradeList struct {
+	Count    int          `json:"count"`
+	Repo     upSlice      `json:"repo"`
+	Aur      upSlice      `json:"aur"`
+	Warnings *aurWarnings `json:"warnings"`
+}
+
+func printUpgradeListJSON(repoUp, aurUp upSlice, warnings *aurWarnings) error {
+	// Always print empty lists as [] instead of null
+	list := upgradeList{
+		len(repoUp) + len(aurUp),
+		append(upSlice{}, repoUp...),
+		append(upSlice{}, aurUp...),
+		&aurWarnings{
+			append([]string{}, warnings.Orphans...),
+			append([]string{}, warnings.OutOfDate...),
+			append([]string{}, warnings.Missing...),
+			append([]heldUpgrade{}, warnings.Held...),
+		},
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	return enc.Encode(list)
+}
+
+func printNumberOfUpdates(parser *arguments) error {
 	warnings := &aurWarnings{}
-	old := os.Stdout // keep backup of the real stdout
-	os.Stdout = nil
-	aurUp, repoUp, err := upList(warnings)
-	os.Stdout = old // restoring the real stdout
+	aurUp, repoUp, err := upList(warnings, ioutil.Discard)
 	if err != nil {
 		return err
 	}
+
+	if parser.existsArg("json") {
+		return printUpgradeListJSON(repoUp, aurUp, warnings)
+	}
+
 	fmt.Println(len(aurUp) + len(repoUp))
 
 	return nil
 }
 
-//TODO: Make it less hacky
 func printUpdateList(parser *arguments) error {
 	targets := sliceToStringSet(parser.targets)
 	warnings := &aurWarnings{}
-	old := os.Stdout // keep backup of the real stdout
-	os.Stdout = nil
 	_, _, localNames, remoteNames, err := filterPackages()
 	if err != nil {
 		return err
 	}
 
-	aurUp, repoUp, err := upList(warnings)
-	os.Stdout = old // restoring the real stdout
+	aurUp, repoUp, err := upList(warnings, ioutil.Discard)
 	if err != nil {
 		return err
 	}
 
 	noTargets := len(targets) == 0
+	var repoList, aurList upSlice
 
 	if !parser.existsArg("m", "foreign") {
 		for _, pkg := range repoUp {
 			if noTargets || targets.get(pkg.Name) {
-				if parser.existsArg("q", "quiet") {
-					fmt.Printf("%s\n", pkg.Name)
-				} else {
-					fmt.Printf("%s %s -> %s\n", bold(pkg.Name), green(pkg.LocalVersion), green(pkg.RemoteVersion))
-				}
+				repoList = append(repoList, pkg)
 				delete(targets, pkg.Name)
 			}
 		}
@@ -345,16 +366,37 @@ func printUpdateList(parser *arguments) error {
 	if !parser.existsArg("n", "native") {
 		for _, pkg := range aurUp {
 			if noTargets || targets.get(pkg.Name) {
-				if parser.existsArg("q", "quiet") {
-					fmt.Printf("%s\n", pkg.Name)
-				} else {
-					fmt.Printf("%s %s -> %s\n", bold(pkg.Name), green(pkg.LocalVersion), green(pkg.RemoteVersion))
-				}
+				aurList = append(aurList, pkg)
 				delete(targets, pkg.Name)
 			}
 		}
 	}
 
+	if parser.existsArg("json") {
+		err = printUpgradeListJSON(repoList, aurList, warnings)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, pkg := range append(repoList, aurList...) {
+			if parser.existsArg("q", "quiet") {
+				fmt.Printf("%s\n", pkg.Name)
+			} else {
+				fmt.Printf("%s %s -> %s\n", bold(pkg.Name), green(pkg.LocalVersion), green(pkg.RemoteVersion))
+			}
+		}
+
+		if !parser.existsArg("q", "quiet") && !parser.existsArg("n", "native") {
+			for _, pkg := range warnings.Held {
+				if noTargets || targets.get(pkg.Name) {
+					fmt.Printf("%s %s -> %s %s\n", bold(pkg.Name), green(pkg.LocalVersion), yellow(pkg.RemoteVersion),
+						yellow("[held: "+strings.TrimSpace(pkg.Constraint+" "+pkg.Reason)+"]"))
+					delete(targets, pkg.Name)
+				}
+			}
+		}
+	}
+
 	missing := false
 
 outer:
@@ -428,7 +470,7 @@ type rss struct {
 }
 
 func printNewsFeed() error {
-	resp, err := http.Get("https://archlinux.org/feeds/news")
+	resp, err := httpClient.Get("https://archlinux.org/feeds/news")
 	if err != nil {
 		return err
 	}
@@ -13,9 +13,10 @@ import (
 )
 
 type aurWarnings struct {
-	Orphans   []string
-	OutOfDate []string
-	Missing   []string
+	Orphans   []string      `json:"orphans"`
+	OutOfDate []string      `json:"outOfDate"`
+	Missing   []string      `json:"missing"`
+	Held      []heldUpgrade `json:"held"`
 }
 
 // Query is a collection of Results
@@ -77,7 +78,8 @@ func filterPackages() (local []alpm.Package, remote []alpm.Package,
 		found := false
 		// For each DB search for our secret package.
 		_ = dbList.ForEach(func(d alpm.Db) error {
-			if found {
+			// Packages in the local repo were built by us from the AUR.
+			if found || d.Name() == config.LocalRepo {
 				return nil
 			}
 			_, err := d.PkgByName(k.Name())
@@ -111,7 +113,7 @@ func narrowSearch(pkgS []string, sortS bool) (aurQuery, error) {
 	}
 
 	for i, word := range pkgS {
-		r, err = rpc.Search(word)
+		r, err = aurClient.Search(word)
 		if err == nil {
 			usedIndex = i
 			break
@@ -482,7 +484,7 @@ func aurInfo(names []string, warnings *aurWarnings) ([]*rpc.Pkg, error) {
 
 	makeRequest := func(n, max int) {
 		defer wg.Done()
-		tempInfo, requestErr := rpc.Info(names[n:max])
+		tempInfo, requestErr := aurClient.Info(names[n:max])
 		errs.Add(requestErr)
 		if requestErr != nil {
 			return
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	alpm "github.com/jguer/go-alpm"
+)
+
+// Why a package is kept, from strongest to weakest. This mirrors
+// hangingPackages: -Yc keeps depends and optdepends, -Ycc only depends and
+// make dependencies never keep anything installed.
+const (
+	keepNone = iota
+	keepMake
+	keepOptional
+	keepRuntime
+)
+
+// rdepPkg is an installed package and everything it depends on. Make and
+// check dependencies are only known for AUR packages.
+type rdepPkg struct {
+	Name         string
+	Explicit     bool
+	Provides     []string
+	Depends      []string
+	OptDepends   []string
+	MakeDepends  []string
+	CheckDepends []string
+}
+
+// rdepEdge says Name depends on a package through a Type dependency.
+type rdepEdge struct {
+	Name string
+	Type string
+}
+
+func keepReason(depType string) int {
+	switch depType {
+	case "depends":
+		return keepRuntime
+	case "optdepends":
+		return keepOptional
+	}
+	return keepMake
+}
+
+// rdepGraph maps every installed package to the packages that depend on it.
+type rdepGraph struct {
+	pkgs  map[string]*rdepPkg
+	rdeps map[string][]rdepEdge
+}
+
+func makeRdepGraph(pkgs []*rdepPkg) *rdepGraph {
+	g := &rdepGraph{
+		pkgs:  make(map[string]*rdepPkg),
+		rdeps: make(map[string][]rdepEdge),
+	}
+
+	provides := make(mapStringSet)
+	for _, pkg := range pkgs {
+		g.pkgs[pkg.Name] = pkg
+		for _, provide := range pkg.Provides {
+			name, _, _ := splitDep(provide)
+			provides.Add(name, pkg.Name)
+		}
+	}
+
+	link := func(from string, deps []string, depType string) {
+		for _, dep := range deps {
+			name, _, _ := splitDep(dep)
+			if _, ok := g.pkgs[name]; ok {
+				g.rdeps[name] = append(g.rdeps[name], rdepEdge{from, depType})
+				continue
+			}
+
+			for provider := range provides[name] {
+				g.rdeps[provider] = append(g.rdeps[provider], rdepEdge{from, depType})
+			}
+		}
+	}
+
+	for _, pkg := range pkgs {
+		link(pkg.Name, pkg.Depends, "depends")
+		link(pkg.Name, pkg.OptDepends, "optdepends")
+		link(pkg.Name, pkg.MakeDepends, "makedepends")
+		link(pkg.Name, pkg.CheckDepends, "checkdepends")
+	}
+
+	return g
+}
+
+// rdepChain is a path from an explicitly installed package down to the
+// package being queried.
+type rdepChain struct {
+	Reason int
+	Edges  []rdepEdge
+}
+
+// format prints the chain starting at the explicit package, for example
+// "foo --depends--> bar --makedepends--> name".
+func (c rdepChain) format(name string) string {
+	if len(c.Edges) == 0 {
+		return name
+	}
+
+	str := c.Edges[len(c.Edges)-1].Name
+	for i := len(c.Edges) - 1; i >= 0; i-- {
+		next := name
+		if i > 0 {
+			next = c.Edges[i-1].Name
+		}
+		str += " --" + c.Edges[i].Type + "--> " + next
+	}
+	return str
+}
+
+// whyDepends finds the explicitly installed packages that need name, along
+// with the strongest reason each one has for doing so. The search is done
+// once per reason so that a package reachable through depends alone is never
+// reported as only needing name to build.
+func (g *rdepGraph) whyDepends(name string) map[string]rdepChain {
+	chains := make(map[string]rdepChain)
+
+	for reason := keepRuntime; reason >= keepMake; reason-- {
+		visited := stringSet{name: struct{}{}}
+		queue := []rdepChain{{reason, []rdepEdge{}}}
+
+		for len(queue) > 0 {
+			chain := queue[0]
+			queue = queue[1:]
+
+			current := name
+			if len(chain.Edges) > 0 {
+				current = chain.Edges[len(chain.Edges)-1].Name
+			}
+
+			for _, edge := range g.rdeps[current] {
+				if keepReason(edge.Type) < reason || visited.get(edge.Name) {
+					continue
+				}
+				visited.set(edge.Name)
+
+				edges := make([]rdepEdge, len(chain.Edges), len(chain.Edges)+1)
+				copy(edges, chain.Edges)
+				next := rdepChain{reason, append(edges, edge)}
+
+				if g.pkgs[edge.Name].Explicit {
+					if _, ok := chains[edge.Name]; !ok {
+						chains[edge.Name] = next
+					}
+					// Explicit packages are kept anyway, whatever needs
+					// them is reported against them instead.
+					continue
+				}
+
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return chains
+}
+
+// installedRdepPkgs collects every installed package, using the AUR to fill
+// in make and check dependencies of foreign packages.
+func installedRdepPkgs() ([]*rdepPkg, error) {
+	_, _, _, remoteNames, err := filterPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	localDb, err := alpmHandle.LocalDb()
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := make([]*rdepPkg, 0)
+	byName := make(map[string]*rdepPkg)
+
+	toStrings := func(list alpm.DependList) []string {
+		deps := make([]string, 0)
+		list.ForEach(func(dep alpm.Depend) error {
+			deps = append(deps, dep.String())
+			return nil
+		})
+		return deps
+	}
+
+	localDb.PkgCache().ForEach(func(pkg alpm.Package) error {
+		rpkg := &rdepPkg{
+			Name:       pkg.Name(),
+			Explicit:   pkg.Reason() == alpm.PkgReasonExplicit,
+			Provides:   toStrings(pkg.Provides()),
+			Depends:    toStrings(pkg.Depends()),
+			OptDepends: toStrings(pkg.OptionalDepends()),
+		}
+		pkgs = append(pkgs, rpkg)
+		byName[rpkg.Name] = rpkg
+		return nil
+	})
+
+	warnings := &aurWarnings{}
+	info, err := aurInfo(remoteNames, warnings)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, aurPkg := range info {
+		if rpkg, ok := byName[aurPkg.Name]; ok {
+			rpkg.MakeDepends = aurPkg.MakeDepends
+			rpkg.CheckDepends = aurPkg.CheckDepends
+		}
+	}
+
+	return pkgs, nil
+}
+
+// printWhyDepends explains why each target is installed.
+func printWhyDepends(targets []string) error {
+	if len(targets) == 0 {
+		return fmt.Errorf("no targets specified")
+	}
+
+	pkgs, err := installedRdepPkgs()
+	if err != nil {
+		return err
+	}
+
+	g := makeRdepGraph(pkgs)
+
+	for _, target := range targets {
+		pkg, ok := g.pkgs[target]
+		if !ok {
+			return fmt.Errorf("package '%s' was not found", target)
+		}
+
+		fmt.Println(bold(cyan("::")), bold(pkg.Name))
+
+		if pkg.Explicit {
+			fmt.Println(bold(green(smallArrow)), "Explicitly installed")
+		}
+
+		direct := make([]string, 0, len(g.rdeps[target]))
+		for _, edge := range g.rdeps[target] {
+			direct = append(direct, edge.Name+" ("+edge.Type+")")
+		}
+		if len(direct) > 0 {
+			fmt.Println(bold(smallArrow), "Required by:", strings.Join(direct, " "))
+		}
+
+		chains := g.whyDepends(target)
+		best := keepNone
+		for _, chain := range chains {
+			if chain.Reason > best {
+				best = chain.Reason
+			}
+		}
+
+		for _, reason := range []int{keepRuntime, keepOptional, keepMake} {
+			for _, name := range sortedKeys(chains) {
+				chain := chains[name]
+				if chain.Reason != reason {
+					continue
+				}
+
+				str := chain.format(target)
+				if reason == keepMake {
+					str += " " + yellow("[make only]")
+				} else if reason == keepOptional {
+					str += " " + yellow("[optional]")
+				}
+				fmt.Println("   ", str)
+			}
+		}
+
+		if pkg.Explicit {
+			continue
+		}
+
+		switch best {
+		case keepRuntime:
+		case keepOptional:
+			fmt.Println(bold(yellow(smallArrow)), "Only kept as an optional dependency, yay -Ycc would remove it")
+		case keepMake:
+			fmt.Println(bold(yellow(smallArrow)), "Only needed to build packages, yay -Yc would remove it")
+		default:
+			fmt.Println(bold(yellow(smallArrow)), "Not needed by any explicitly installed package, yay -Yc would remove it")
+		}
+	}
+
+	return nil
+}
+
+func sortedKeys(chains map[string]rdepChain) []string {
+	keys := make([]string, 0, len(chains))
+	for name := range chains {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys
+}
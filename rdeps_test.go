@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestWhyDepends(t *testing.T) {
+	pkgs := []*rdepPkg{
+		{Name: "app", Explicit: true, Depends: []string{"libfoo>=1"}, MakeDepends: []string{"cmake"}},
+		{Name: "libfoo", Depends: []string{"sh"}},
+		{Name: "bash", Provides: []string{"sh=5.0"}},
+		{Name: "editor", Explicit: true, OptDepends: []string{"libfoo"}},
+		{Name: "cmake", Depends: []string{"libuv"}},
+		{Name: "libuv"},
+		{Name: "orphan"},
+	}
+
+	type chain struct {
+		reason int
+		str    string
+	}
+
+	testCases := []struct {
+		name string
+		want map[string]chain
+	}{
+		{"libfoo", map[string]chain{
+			"app":    {keepRuntime, "app --depends--> libfoo"},
+			"editor": {keepOptional, "editor --optdepends--> libfoo"},
+		}},
+		{"bash", map[string]chain{
+			"app":    {keepRuntime, "app --depends--> libfoo --depends--> bash"},
+			"editor": {keepOptional, "editor --optdepends--> libfoo --depends--> bash"},
+		}},
+		{"libuv", map[string]chain{
+			"app": {keepMake, "app --makedepends--> cmake --depends--> libuv"},
+		}},
+		{"orphan", map[string]chain{}},
+	}
+
+	g := makeRdepGraph(pkgs)
+
+	for _, tc := range testCases {
+		got := g.whyDepends(tc.name)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: expected %d chains, got %v", tc.name, len(tc.want), got)
+		}
+
+		for name, want := range tc.want {
+			c, ok := got[name]
+			if !ok {
+				t.Fatalf("%s: expected a chain from %s", tc.name, name)
+			}
+			if c.Reason != want.reason {
+				t.Fatalf("%s: expected reason %d from %s, got %d", tc.name, want.reason, name, c.Reason)
+			}
+			if str := c.format(tc.name); str != want.str {
+				t.Fatalf("%s: expected '%s', got '%s'", tc.name, want.str, str)
+			}
+		}
+	}
+}
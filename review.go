@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	gosrc "github.com/Morganamilo/go-srcinfo"
+)
+
+// Results of a review command. A command exiting 0 passes the base, exiting 1
+// warns about it and anything else blocks it from being built.
+const (
+	reviewPass = iota
+	reviewWarn
+	reviewBlock
+)
+
+type reviewResult struct {
+	Cmd    string
+	Status int
+	Output string
+}
+
+// reviewCmds returns the configured review commands, each split into its
+// arguments.
+func reviewCmds() [][]string {
+	cmds := make([][]string, 0)
+	for _, cmd := range strings.Split(config.ReviewCmds, ":") {
+		if args := strings.Fields(cmd); len(args) > 0 {
+			cmds = append(cmds, args)
+		}
+	}
+	return cmds
+}
+
+// runReviewCmd runs a review command on the base in dir. The command gets the
+// directory as its last argument, PKGBASE in its environment and the parsed
+// .SRCINFO as JSON on stdin.
+func runReviewCmd(args []string, pkgbase string, dir string, srcinfo *gosrc.Srcinfo) reviewResult {
+	result := reviewResult{Cmd: strings.Join(args, " ")}
+
+	input, err := json.Marshal(srcinfo)
+	if err != nil {
+		result.Status = reviewBlock
+		result.Output = err.Error()
+		return result
+	}
+
+	var out bytes.Buffer
+	cmd := exec.Command(args[0], append(args[1:], dir)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "PKGBASE="+pkgbase)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err = cmd.Run()
+	result.Output = strings.TrimSpace(out.String())
+
+	if err == nil {
+		return result
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.Sys().(syscall.WaitStatus).ExitStatus() == 1 {
+		result.Status = reviewWarn
+	} else {
+		result.Status = reviewBlock
+		if result.Output == "" {
+			result.Output = err.Error()
+		}
+	}
+
+	return result
+}
+
+// reviewPkgbuilds passes every base to the configured review commands and
+// returns the results for each pkgbase.
+func reviewPkgbuilds(bases []Base, srcinfos map[string]*gosrc.Srcinfo) map[string][]reviewResult {
+	cmds := reviewCmds()
+	results := make(map[string][]reviewResult)
+
+	for _, base := range bases {
+		pkg := base.Pkgbase()
+		dir := filepath.Join(config.BuildDir, pkg)
+
+		for _, args := range cmds {
+			results[pkg] = append(results[pkg], runReviewCmd(args, pkg, dir, srcinfos[pkg]))
+		}
+	}
+
+	return results
+}
+
+func worstReview(results []reviewResult) int {
+	status := reviewPass
+	for _, result := range results {
+		if result.Status > status {
+			status = result.Status
+		}
+	}
+	return status
+}
+
+// reviewNumberMenu prints the review results and asks which blocked bases
+// should be built anyway. It returns an error if any blocked base is not
+// overridden.
+func reviewNumberMenu(bases []Base, results map[string][]reviewResult) error {
+	toPrint := ""
+	blocked := make([]string, 0)
+
+	for n, base := range bases {
+		pkg := base.Pkgbase()
+		status := worstReview(results[pkg])
+		if status == reviewPass {
+			continue
+		}
+
+		toPrint += fmt.Sprintf(magenta("%3d")+" %-40s", len(bases)-n, bold(base.String()))
+		if status == reviewBlock {
+			toPrint += bold(red(" (Blocked)"))
+			blocked = append(blocked, pkg)
+		} else {
+			toPrint += bold(yellow(" (Warning)"))
+		}
+		toPrint += "\n"
+
+		for _, result := range results[pkg] {
+			if result.Status == reviewPass {
+				continue
+			}
+
+			toPrint += "    " + bold(result.Cmd) + "\n"
+			for _, line := range strings.Split(result.Output, "\n") {
+				if line != "" {
+					toPrint += "        " + line + "\n"
+				}
+			}
+		}
+	}
+
+	if toPrint == "" {
+		return nil
+	}
+
+	fmt.Println(bold(cyan("::") + " PKGBUILD review:"))
+	fmt.Print(toPrint)
+
+	if len(blocked) == 0 {
+		return nil
+	}
+
+	fmt.Println(bold(green(arrow + " Blocked packages to build anyway?")))
+	fmt.Println(bold(green(arrow) + cyan(" [N]one ") + "[A]ll [Ab]ort or (1 2 3, 1-3, ^4)"))
+	fmt.Print(bold(green(arrow + " ")))
+	input, err := getInput("")
+	if err != nil {
+		return err
+	}
+
+	include, exclude, otherInclude, otherExclude := parseNumberMenu(input)
+	isInclude := len(exclude) == 0 && len(otherExclude) == 0
+
+	if otherInclude.get("abort") || otherInclude.get("ab") {
+		return fmt.Errorf("Aborting due to user")
+	}
+
+	remaining := make([]string, 0)
+	for i, base := range bases {
+		pkg := base.Pkgbase()
+		if worstReview(results[pkg]) != reviewBlock {
+			continue
+		}
+
+		var override bool
+		switch {
+		case otherInclude.get("n") || otherInclude.get("none"):
+		case otherInclude.get("a") || otherInclude.get("all"):
+			override = true
+		case isInclude:
+			override = include.get(len(bases)-i) || otherInclude.get(pkg)
+		default:
+			override = !exclude.get(len(bases)-i) && !otherExclude.get(pkg)
+		}
+
+		if !override {
+			remaining = append(remaining, pkg)
+		}
+	}
+
+	if len(remaining) > 0 {
+		return fmt.Errorf("Aborting due to review of: %s", strings.Join(remaining, " "))
+	}
+
+	return nil
+}
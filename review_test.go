@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gosrc "github.com/Morganamilo/go-srcinfo"
+)
+
+func TestRunReviewCmd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yay-review")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	srcinfo := &gosrc.Srcinfo{}
+	srcinfo.Pkgbase = "foo"
+
+	testCases := []struct {
+		script string
+		status int
+		output string
+	}{
+		{"exit 0", reviewPass, ""},
+		{"echo \"$PKGBASE $(basename \"$1\")\"; exit 1", reviewWarn, "foo " + filepath.Base(dir)},
+		{"grep -q '\"Pkgbase\":\"foo\"' && echo blocked; exit 2", reviewBlock, "blocked"},
+	}
+
+	for n, tc := range testCases {
+		script := filepath.Join(dir, "review.sh")
+		if err = ioutil.WriteFile(script, []byte(tc.script), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		result := runReviewCmd([]string{"sh", script}, "foo", dir, srcinfo)
+		if result.Status != tc.status {
+			t.Fatalf("%d: expected status %d, got %d: %s", n, tc.status, result.Status, result.Output)
+		}
+		if result.Output != tc.output {
+			t.Fatalf("%d: expected output '%s', got '%s'", n, tc.output, result.Output)
+		}
+	}
+}
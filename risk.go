@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	gosrc "github.com/Morganamilo/go-srcinfo"
+)
+
+// pipeToShell matches downloads piped straight into a shell.
+var pipeToShell = regexp.MustCompile(`\b(curl|wget)\b[^|#]*\|\s*(sudo\s+)?(ba|z|da)?sh\b`)
+
+// vcsProtocols are the source prefixes makepkg treats as VCS sources.
+var vcsProtocols = []string{"bzr", "fossil", "git", "hg", "svn"}
+
+// pkgbuildSnapshot is the state of a base at one revision: its .SRCINFO along
+// with the content of the PKGBUILD and any install scriptlets.
+type pkgbuildSnapshot struct {
+	srcinfo *gosrc.Srcinfo
+	files   map[string]string
+}
+
+// installFiles lists the install scriptlets used by any package of the base.
+func (s *pkgbuildSnapshot) installFiles() []string {
+	files := make(stringSet)
+	for _, pkg := range s.srcinfo.SplitPackages() {
+		if pkg.Install != "" {
+			files.set(pkg.Install)
+		}
+	}
+
+	list := files.toSlice()
+	sort.Strings(list)
+	return list
+}
+
+// gitSnapshot reads a snapshot of the base in dir at rev.
+func gitSnapshot(dir string, rev string) (*pkgbuildSnapshot, error) {
+	gitShow := func(file string) (string, error) {
+		stdout, stderr, err := capture(passToGit(dir, "show", rev+":"+file))
+		if err != nil {
+			return "", fmt.Errorf("%s%s", stderr, err)
+		}
+		return stdout, nil
+	}
+
+	data, err := gitShow(".SRCINFO")
+	if err != nil {
+		return nil, err
+	}
+
+	srcinfo, err := gosrc.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &pkgbuildSnapshot{srcinfo, make(map[string]string)}
+	for _, file := range append([]string{"PKGBUILD"}, snapshot.installFiles()...) {
+		if content, err := gitShow(file); err == nil {
+			snapshot.files[file] = content
+		}
+	}
+
+	return snapshot, nil
+}
+
+// dirSnapshot reads a snapshot of the base from the files in dir.
+func dirSnapshot(dir string) (*pkgbuildSnapshot, error) {
+	srcinfo, err := gosrc.ParseFile(filepath.Join(dir, ".SRCINFO"))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &pkgbuildSnapshot{srcinfo, make(map[string]string)}
+	for _, file := range append([]string{"PKGBUILD"}, snapshot.installFiles()...) {
+		if content, err := ioutil.ReadFile(filepath.Join(dir, file)); err == nil {
+			snapshot.files[file] = string(content)
+		}
+	}
+
+	return snapshot, nil
+}
+
+// splitSource splits a source entry into its url and whether it is a VCS
+// source. The url is nil for local files.
+func splitSource(source string) (*url.URL, bool) {
+	if i := strings.Index(source, "::"); i != -1 {
+		source = source[i+2:]
+	}
+
+	vcs := false
+	for _, protocol := range vcsProtocols {
+		if strings.HasPrefix(source, protocol+"+") || strings.HasPrefix(source, protocol+"://") {
+			source = strings.TrimPrefix(source, protocol+"+")
+			vcs = true
+			break
+		}
+	}
+
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return nil, vcs
+	}
+
+	return u, vcs
+}
+
+func sourceHosts(srcinfo *gosrc.Srcinfo) stringSet {
+	hosts := make(stringSet)
+	for _, source := range srcinfo.Source {
+		if u, _ := splitSource(source.Value); u != nil {
+			hosts.set(u.Hostname())
+		}
+	}
+	return hosts
+}
+
+// skippedSources lists the non VCS sources that have no checksum other than
+// SKIP.
+func skippedSources(srcinfo *gosrc.Srcinfo) []string {
+	sums := [][]gosrc.ArchString{
+		srcinfo.MD5Sums,
+		srcinfo.SHA1Sums,
+		srcinfo.SHA224Sums,
+		srcinfo.SHA256Sums,
+		srcinfo.SHA384Sums,
+		srcinfo.SHA512Sums,
+	}
+
+	arches := make(stringSet)
+	for _, source := range srcinfo.Source {
+		arches.set(source.Arch)
+	}
+
+	skipped := make([]string, 0)
+	for arch := range arches {
+		sources := make([]string, 0)
+		for _, source := range srcinfo.Source {
+			if source.Arch == arch {
+				sources = append(sources, source.Value)
+			}
+		}
+
+		for i, source := range sources {
+			if _, vcs := splitSource(source); vcs {
+				continue
+			}
+
+			checked, skip := false, false
+			for _, kind := range sums {
+				n := 0
+				for _, sum := range kind {
+					if sum.Arch != arch {
+						continue
+					}
+					if n == i {
+						if sum.Value == "SKIP" {
+							skip = true
+						} else {
+							checked = true
+						}
+					}
+					n++
+				}
+			}
+
+			if skip && !checked {
+				skipped = append(skipped, source)
+			}
+		}
+	}
+
+	sort.Strings(skipped)
+	return skipped
+}
+
+func pipeToShellLines(content string) stringSet {
+	lines := make(stringSet)
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") && pipeToShell.MatchString(line) {
+			lines.set(line)
+		}
+	}
+	return lines
+}
+
+// pkgbuildRisks compares two snapshots of a base and describes anything
+// worth a closer look in next. prev is nil for a base that has never been
+// seen before.
+func pkgbuildRisks(prev, next *pkgbuildSnapshot) []string {
+	risks := make([]string, 0)
+
+	if prev != nil {
+		oldHosts := sourceHosts(prev.srcinfo)
+		newHosts := sourceHosts(next.srcinfo).toSlice()
+		sort.Strings(newHosts)
+		if len(oldHosts) > 0 {
+			for _, host := range newHosts {
+				if !oldHosts.get(host) {
+					risks = append(risks, "New source host: "+host)
+				}
+			}
+		}
+
+		oldMaintainer := parseMaintainer(strings.NewReader(prev.files["PKGBUILD"]))
+		newMaintainer := parseMaintainer(strings.NewReader(next.files["PKGBUILD"]))
+		if oldMaintainer != newMaintainer {
+			risks = append(risks, fmt.Sprintf("Maintainer changed: %s -> %s", oldMaintainer, newMaintainer))
+		}
+	}
+
+	for _, source := range skippedSources(next.srcinfo) {
+		risks = append(risks, "Checksum is SKIP for non VCS source: "+source)
+	}
+
+	oldInstalls := make(stringSet)
+	if prev != nil {
+		for _, file := range prev.installFiles() {
+			oldInstalls.set(file)
+		}
+	}
+	for _, file := range next.installFiles() {
+		if !oldInstalls.get(file) {
+			risks = append(risks, "New install scriptlet: "+file)
+		}
+	}
+
+	files := make([]string, 0, len(next.files))
+	for file := range next.files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		oldLines := make(stringSet)
+		if prev != nil {
+			oldLines = pipeToShellLines(prev.files[file])
+		}
+
+		newLines := pipeToShellLines(next.files[file]).toSlice()
+		sort.Strings(newLines)
+		for _, line := range newLines {
+			if !oldLines.get(line) {
+				risks = append(risks, fmt.Sprintf("Download piped to a shell in %s: %s", file, line))
+			}
+		}
+	}
+
+	return risks
+}
+
+// printPkgbuildRisks prints the risks found for base.
+func printPkgbuildRisks(base Base, risks []string) {
+	for _, risk := range risks {
+		fmt.Printf("%s %s: %s\n", bold(red(arrow)), cyan(base.String()), bold(risk))
+	}
+}
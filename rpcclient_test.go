@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRPCClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yay-rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var mux sync.Mutex
+	requests := 0
+	notModified := 0
+	failures := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		requests++
+
+		if r.URL.Query().Get("arg[]") == "flaky" && failures < 2 {
+			failures++
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprintf(w, `{"version":5,"type":"multiinfo","resultcount":1,"results":[{"Name":"%s","Version":"1.0-1"}]}`, r.URL.Query().Get("arg[]"))
+	}))
+	defer server.Close()
+
+	client := newRPCClient(server.URL+"/rpc.php?", server.Client(), dir, 4)
+	client.backoff = time.Millisecond
+
+	info := func(name string) {
+		pkgs, err := client.Info([]string{name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pkgs) != 1 || pkgs[0].Name != name {
+			t.Fatalf("expected %s, got %v", name, pkgs)
+		}
+	}
+
+	info("foo")
+	info("foo")
+	if requests != 1 {
+		t.Fatalf("expected the second request to be cached, got %d requests", requests)
+	}
+
+	client.ttl = 0
+	info("foo")
+	if requests != 2 || notModified != 1 {
+		t.Fatalf("expected a conditional request, got %d requests and %d not modified", requests, notModified)
+	}
+
+	info("flaky")
+	if failures != 2 || requests != 5 {
+		t.Fatalf("expected two retries, got %d failures and %d requests", failures, requests)
+	}
+
+	client.retries = 0
+	failures = 0
+	if _, err = client.Info([]string{"flaky"}); err == nil {
+		t.Fatalf("expected an error without retries")
+	}
+}
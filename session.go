@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// session holds the state of an install once all menus have been answered and
+// sources downloaded, so a failed build can be resumed with --resume.
+type session struct {
+	Op           string            `json:"op"`
+	Options      map[string]string `json:"options"`
+	Globals      map[string]string `json:"globals"`
+	Targets      []string          `json:"targets"`
+	Aur          []Base            `json:"aur"`
+	Runtime      stringSet         `json:"runtime"`
+	Explicit     stringSet         `json:"explicit"`
+	Incompatible stringSet         `json:"incompatible"`
+	Conflicts    mapStringSet      `json:"conflicts"`
+	MakeDeps     []string          `json:"makedeps"`
+	Installed    stringSet         `json:"installed"`
+	Failed       string            `json:"failed"`
+}
+
+func makeSession(parser *arguments, ds *depSolver, incompatible stringSet, conflicts mapStringSet, removeMake bool) *session {
+	sess := &session{
+		Op:           parser.op,
+		Options:      parser.options,
+		Globals:      parser.globals,
+		Targets:      parser.targets,
+		Aur:          ds.Aur,
+		Runtime:      ds.Runtime,
+		Explicit:     ds.Explicit,
+		Incompatible: incompatible,
+		Conflicts:    conflicts,
+		MakeDeps:     make([]string, 0),
+		Installed:    make(stringSet),
+	}
+
+	if removeMake {
+		sess.MakeDeps = ds.getMake()
+	}
+
+	return sess
+}
+
+func loadSession() (*session, error) {
+	sfile, err := os.Open(sessionFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to open session file '%s': %s", sessionFile, err)
+	}
+	defer sfile.Close()
+
+	sess := &session{}
+	decoder := json.NewDecoder(sfile)
+	if err = decoder.Decode(sess); err != nil {
+		return nil, fmt.Errorf("Failed to read session '%s': %s", sessionFile, err)
+	}
+
+	if sess.Installed == nil {
+		sess.Installed = make(stringSet)
+	}
+
+	return sess, nil
+}
+
+func (sess *session) save() error {
+	marshalledinfo, err := json.MarshalIndent(sess, "", "\t")
+	if err != nil {
+		return err
+	}
+	in, err := os.OpenFile(sessionFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_, err = in.Write(marshalledinfo)
+	if err != nil {
+		return err
+	}
+	err = in.Sync()
+	return err
+}
+
+func (sess *session) arguments() *arguments {
+	parser := makeArguments()
+	parser.op = sess.Op
+	for k, v := range sess.Options {
+		parser.options[k] = v
+	}
+	for k, v := range sess.Globals {
+		parser.globals[k] = v
+	}
+	parser.targets = append(parser.targets, sess.Targets...)
+	return parser
+}
+
+// setInstalled records that pkgbase no longer needs to be built.
+func (sess *session) setInstalled(pkgbase string) {
+	sess.Installed.set(pkgbase)
+	if err := sess.save(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// setFailed records the base that stopped the install.
+func (sess *session) setFailed(pkgbase string) {
+	sess.Failed = pkgbase
+	if err := sess.save(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, bold(red(arrow)), bold("Install stopped at "+pkgbase+", run yay --resume to continue"))
+}
+
+// finish removes make dependencies and cleans the build dirs as a normal
+// install would, then forgets the session.
+func (sess *session) finish() error {
+	if len(sess.MakeDeps) > 0 {
+		removeArguments := makeArguments()
+		removeArguments.addArg("R", "u")
+		removeArguments.addTarget(sess.MakeDeps...)
+
+		oldValue := config.NoConfirm
+		config.NoConfirm = true
+		err := show(passToPacman(removeArguments))
+		config.NoConfirm = oldValue
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.CleanAfter {
+		cleanAfter(sess.Aur)
+	}
+
+	if err := os.Remove(sessionFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// dependentBases returns the index of failed and of every base that depends on
+// it, directly or through another base.
+func dependentBases(bases []Base, failed int) map[int]bool {
+	deps := baseDependencies(bases)
+	blocked := map[int]bool{failed: true}
+
+	for changed := true; changed; {
+		changed = false
+		for i := range bases {
+			if blocked[i] {
+				continue
+			}
+
+			for _, dep := range deps[i] {
+				if blocked[dep] {
+					blocked[i] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	return blocked
+}
+
+// resumeInstall continues the install saved by a previous run that failed.
+func resumeInstall() error {
+	sess, err := loadSession()
+	if err != nil {
+		return err
+	}
+	if sess == nil {
+		return fmt.Errorf("no install to resume")
+	}
+
+	remaining := make([]Base, 0, len(sess.Aur))
+	for _, base := range sess.Aur {
+		if !sess.Installed.get(base.Pkgbase()) {
+			remaining = append(remaining, base)
+		}
+	}
+
+	if cmdArgs.existsArg("skipfailed") && sess.Failed != "" {
+		failed := -1
+		for i, base := range remaining {
+			if base.Pkgbase() == sess.Failed {
+				failed = i
+			}
+		}
+
+		if failed != -1 {
+			blocked := dependentBases(remaining, failed)
+			skipped := make([]string, 0, len(blocked))
+			skippedNames := make(stringSet)
+			kept := make([]Base, 0, len(remaining))
+
+			for i, base := range remaining {
+				if blocked[i] {
+					skipped = append(skipped, base.Pkgbase())
+					for _, pkg := range base {
+						skippedNames.set(pkg.Name)
+					}
+				} else {
+					kept = append(kept, base)
+				}
+			}
+
+			// Skipped make dependencies were never installed.
+			makeDeps := make([]string, 0, len(sess.MakeDeps))
+			for _, name := range sess.MakeDeps {
+				if !skippedNames.get(name) {
+					makeDeps = append(makeDeps, name)
+				}
+			}
+
+			sort.Strings(skipped)
+			fmt.Println(bold(yellow(arrow)), bold("Skipping:"), cyan(strings.Join(skipped, " ")))
+			remaining = kept
+			sess.MakeDeps = makeDeps
+			sess.Failed = ""
+		}
+	}
+
+	if len(remaining) == 0 {
+		fmt.Println(" there is nothing to do")
+		return sess.finish()
+	}
+
+	names := make([]string, 0, len(remaining))
+	for _, base := range remaining {
+		names = append(names, base.Pkgbase())
+	}
+	fmt.Println(bold(cyan("::")+" Resuming install of:"), cyan(strings.Join(names, " ")))
+
+	localDb, err := alpmHandle.LocalDb()
+	if err != nil {
+		return err
+	}
+
+	ds := &depSolver{
+		Aur:      remaining,
+		Runtime:  sess.Runtime,
+		Explicit: sess.Explicit,
+		LocalDb:  localDb,
+	}
+
+	srcinfos, err := parseSrcinfoFiles(ds.Aur, true)
+	if err != nil {
+		return err
+	}
+
+	if config.SudoLoop {
+		sudoLoopBackground()
+	}
+
+	err = buildInstallPkgbuilds(ds, srcinfos, sess.arguments(), sess.Incompatible, sess.Conflicts, sess)
+	if err != nil {
+		return err
+	}
+
+	return sess.finish()
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestDependentBases(t *testing.T) {
+	bases := makeTestBases()
+
+	expected := map[string][]int{
+		"a": {0, 1, 3},
+		"c": {2},
+		"e": {3, 4},
+	}
+
+	for name, want := range expected {
+		failed := -1
+		for i, base := range bases {
+			if base.Pkgbase() == name {
+				failed = i
+			}
+		}
+
+		blocked := dependentBases(bases, failed)
+		if len(blocked) != len(want) {
+			t.Fatalf("%s: expected %v got %v", name, want, blocked)
+		}
+
+		for _, i := range want {
+			if !blocked[i] {
+				t.Fatalf("%s: expected %v got %v", name, want, blocked)
+			}
+		}
+	}
+}
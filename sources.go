@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+)
+
+// sourceProtocol returns the VCS protocol of a source, or "" if it is not a
+// VCS source.
+func sourceProtocol(source string) string {
+	for _, protocol := range vcsProtocols {
+		if strings.HasPrefix(source, protocol+"+") || strings.HasPrefix(source, protocol+"://") {
+			return protocol
+		}
+	}
+
+	return ""
+}
+
+// sourceFilename returns the name makepkg gives a source entry once it has
+// been downloaded, following get_filename in makepkg.
+func sourceFilename(source string) string {
+	if i := strings.Index(source, "::"); i != -1 {
+		return source[:i]
+	}
+
+	protocol := sourceProtocol(source)
+	if protocol == "" {
+		return source[strings.LastIndex(source, "/")+1:]
+	}
+
+	name := strings.SplitN(source, "#", 2)[0]
+	name = strings.SplitN(name, "?", 2)[0]
+	name = strings.TrimSuffix(name, "/")
+	name = name[strings.LastIndex(name, "/")+1:]
+	if protocol == "git" {
+		if i := strings.Index(name, ".git"); i != -1 {
+			name = name[:i]
+		}
+	}
+
+	return name
+}
+
+// failedSources picks the source entries makepkg reported a problem with out
+// of its output.
+func failedSources(output string, sources []string) []string {
+	lines := strings.Split(output, "\n")
+	failed := make([]string, 0)
+
+	for _, source := range sources {
+		name := sourceFilename(source)
+		if name == "" {
+			continue
+		}
+
+		for _, line := range lines {
+			line = strings.TrimSpace(line)
+			if strings.HasSuffix(line, "Failure while downloading "+name) ||
+				strings.Contains(line, "Failure while downloading "+name+" ") ||
+				strings.Contains(line, "ERROR: "+name+" was not found") ||
+				strings.HasPrefix(line, name+" ... FAILED") ||
+				strings.HasPrefix(line, name+" ... NOT FOUND") {
+				failed = append(failed, source)
+				break
+			}
+		}
+	}
+
+	return failed
+}
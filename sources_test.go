@@ -0,0 +1,40 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFailedSources(t *testing.T) {
+	sources := []string{
+		"https://example.com/foo-1.0.tar.gz",
+		"foo-data.zip::https://example.com/data.zip",
+		"git+https://github.com/foo/foo.git#tag=v1.0",
+		"git+https://github.com/foo/foobar.git",
+		"fix.patch",
+		"foo.install",
+	}
+
+	output := `==> Making package: foo 1.0-1
+==> Retrieving sources...
+  -> Downloading foo-1.0.tar.gz...
+curl: (22) The requested URL returned error: 404
+==> ERROR: Failure while downloading foo-1.0.tar.gz
+  -> Cloning foobar git repo...
+==> ERROR: Failure while downloading foobar git repo
+==> Validating source files with sha256sums...
+    foo-data.zip ... FAILED
+    foo.install ... Passed
+==> ERROR: fix.patch was not found in the build directory and is not a URL.`
+
+	expected := []string{
+		"https://example.com/foo-1.0.tar.gz",
+		"foo-data.zip::https://example.com/data.zip",
+		"git+https://github.com/foo/foobar.git",
+		"fix.patch",
+	}
+
+	if failed := failedSources(output, sources); !reflect.DeepEqual(failed, expected) {
+		t.Fatalf("expected %v, got %v", expected, failed)
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gosrc "github.com/Morganamilo/go-srcinfo"
+)
+
+// srcCacheEntry is a source kept in the source cache.
+type srcCacheEntry struct {
+	URL  string `json:"url"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	Used int64  `json:"used"`
+}
+
+// srcCache keeps the sources downloaded by makepkg in config.SrcDest so they
+// outlive the build directories. Each source is stored once in sources/
+// under the hash of its URL. Every base gets a SRCDEST of its own in bases/
+// holding links to the sources it uses, which makepkg downloads any missing
+// sources into before they are moved into sources/.
+type srcCache struct {
+	root    string
+	entries map[string]*srcCacheEntry
+	locks   map[string]*sync.Mutex
+	mux     sync.Mutex
+}
+
+// cachedSource returns the name makepkg saves a source as in SRCDEST and the
+// key it is cached under. Local files are not cached.
+func cachedSource(source string) (string, string, bool) {
+	name := sourceFilename(source)
+	if i := strings.Index(source, "::"); i != -1 {
+		source = source[i+2:]
+	}
+
+	if name == "" || !strings.Contains(source, "://") {
+		return "", "", false
+	}
+
+	key := source
+	if sourceProtocol(source) != "" {
+		// VCS sources are cloned once no matter which revision is used
+		key = strings.SplitN(key, "#", 2)[0]
+		key = strings.SplitN(key, "?", 2)[0]
+	}
+
+	sum := sha1.Sum([]byte(key))
+	return name, hex.EncodeToString(sum[:]), true
+}
+
+// srcCacheDir returns the SRCDEST to give makepkg when working on pkgbase,
+// or "" if there is none.
+func srcCacheDir(pkgbase string) string {
+	if config.SrcDest == "" {
+		return ""
+	}
+
+	dir := filepath.Join(config.SrcDest, "bases", pkgbase)
+	if !dirExists(dir) {
+		return ""
+	}
+
+	return dir
+}
+
+// openSrcCache reads the source cache index. It returns nil if no source
+// cache is configured.
+func openSrcCache() (*srcCache, error) {
+	if config.SrcDest == "" {
+		return nil, nil
+	}
+
+	cache := &srcCache{
+		root:    config.SrcDest,
+		entries: make(map[string]*srcCacheEntry),
+		locks:   make(map[string]*sync.Mutex),
+	}
+
+	if err := os.MkdirAll(filepath.Join(cache.root, "sources"), 0755); err != nil {
+		return nil, err
+	}
+
+	index := filepath.Join(cache.root, "index.json")
+	file, err := os.Open(index)
+	if os.IsNotExist(err) {
+		return cache, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to open source cache index '%s': %s", index, err)
+	}
+	defer file.Close()
+
+	if err = json.NewDecoder(file).Decode(&cache.entries); err != nil {
+		return nil, fmt.Errorf("Failed to read source cache index '%s': %s", index, err)
+	}
+
+	return cache, nil
+}
+
+func (c *srcCache) save() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	marshalledinfo, err := json.MarshalIndent(c.entries, "", "\t")
+	if err != nil {
+		return err
+	}
+	in, err := os.OpenFile(filepath.Join(c.root, "index.json"), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_, err = in.Write(marshalledinfo)
+	if err != nil {
+		return err
+	}
+	err = in.Sync()
+	return err
+}
+
+func (c *srcCache) sourcePath(key string) string {
+	return filepath.Join(c.root, "sources", key)
+}
+
+func (c *srcCache) sources(srcinfo *gosrc.Srcinfo) []string {
+	return archValues(srcinfo.Source, localArch())
+}
+
+// lock takes the lock of every cached source of srcinfo so that bases sharing
+// a source do not download or adopt it at the same time. The locks are taken
+// in order to avoid deadlocks. The returned function releases them.
+func (c *srcCache) lock(srcinfo *gosrc.Srcinfo) func() {
+	seen := make(stringSet)
+	keys := make([]string, 0)
+	for _, source := range c.sources(srcinfo) {
+		if _, key, ok := cachedSource(source); ok && !seen.get(key) {
+			seen.set(key)
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	locks := make([]*sync.Mutex, 0, len(keys))
+	c.mux.Lock()
+	for _, key := range keys {
+		if _, ok := c.locks[key]; !ok {
+			c.locks[key] = &sync.Mutex{}
+		}
+		locks = append(locks, c.locks[key])
+	}
+	c.mux.Unlock()
+
+	for _, lock := range locks {
+		lock.Lock()
+	}
+
+	return func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Unlock()
+		}
+	}
+}
+
+// link creates the SRCDEST of pkgbase, linking every source already in the
+// cache.
+func (c *srcCache) link(pkgbase string, srcinfo *gosrc.Srcinfo) error {
+	dir := filepath.Join(c.root, "bases", pkgbase)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for _, source := range c.sources(srcinfo) {
+		name, key, ok := cachedSource(source)
+		if !ok {
+			continue
+		}
+
+		path := c.sourcePath(key)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+
+		if err := os.Symlink(path, filepath.Join(dir, name)); err != nil && !os.IsExist(err) {
+			return err
+		}
+
+		c.mux.Lock()
+		if entry, ok := c.entries[key]; ok {
+			entry.Used = now
+		}
+		c.mux.Unlock()
+	}
+
+	return nil
+}
+
+// adopt moves the sources makepkg downloaded into the SRCDEST of pkgbase into
+// the cache and updates the size of the ones it already had.
+func (c *srcCache) adopt(pkgbase string, srcinfo *gosrc.Srcinfo) error {
+	dir := filepath.Join(c.root, "bases", pkgbase)
+	now := time.Now().Unix()
+
+	for _, source := range c.sources(srcinfo) {
+		name, key, ok := cachedSource(source)
+		if !ok {
+			continue
+		}
+
+		link := filepath.Join(dir, name)
+		stat, err := os.Lstat(link)
+		if err != nil {
+			continue
+		}
+
+		path := c.sourcePath(key)
+		if stat.Mode()&os.ModeSymlink == 0 {
+			if err = os.RemoveAll(path); err != nil {
+				return err
+			}
+			if err = os.Rename(link, path); err != nil {
+				return err
+			}
+			if err = os.Symlink(path, link); err != nil {
+				return err
+			}
+		}
+
+		c.mux.Lock()
+		c.entries[key] = &srcCacheEntry{
+			URL:  strings.TrimPrefix(source, name+"::"),
+			Name: name,
+			Size: diskUsage(path),
+			Used: now,
+		}
+		c.mux.Unlock()
+	}
+
+	return nil
+}
+
+func diskUsage(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func (c *srcCache) size() int64 {
+	var size int64
+	for _, entry := range c.entries {
+		size += entry.Size
+	}
+	return size
+}
+
+// srcCacheExpired returns the entries to remove so that none was last used
+// more than maxAge ago and the total size is at most maxSize. The least
+// recently used entries are removed first. A limit of zero is not applied.
+func srcCacheExpired(entries map[string]*srcCacheEntry, now time.Time, maxAge time.Duration, maxSize int64) []string {
+	keys := make([]string, 0, len(entries))
+	var total int64
+	for key, entry := range entries {
+		keys = append(keys, key)
+		total += entry.Size
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if entries[keys[i]].Used != entries[keys[j]].Used {
+			return entries[keys[i]].Used < entries[keys[j]].Used
+		}
+		return keys[i] < keys[j]
+	})
+
+	expired := make([]string, 0)
+	for _, key := range keys {
+		entry := entries[key]
+		old := maxAge > 0 && now.Sub(time.Unix(entry.Used, 0)) > maxAge
+		big := maxSize > 0 && total > maxSize
+		if !old && !big {
+			continue
+		}
+
+		expired = append(expired, key)
+		total -= entry.Size
+	}
+
+	return expired
+}
+
+// remove deletes sources from the cache and returns the space freed. The
+// SRCDEST of every base is removed too as it is made again before each use.
+func (c *srcCache) remove(keys []string) (int64, error) {
+	var freed int64
+
+	for _, key := range keys {
+		if err := os.RemoveAll(c.sourcePath(key)); err != nil {
+			return freed, err
+		}
+
+		if entry, ok := c.entries[key]; ok {
+			freed += entry.Size
+		}
+		delete(c.entries, key)
+	}
+
+	if err := os.RemoveAll(filepath.Join(c.root, "bases")); err != nil {
+		return freed, err
+	}
+
+	return freed, c.save()
+}
+
+// cleanSrcCache prunes the source cache by the limits given with
+// --srcmaxage and --srcmaxsize, or asks to empty it if there are none.
+func cleanSrcCache(parser *arguments, removeAll bool) error {
+	cache, err := openSrcCache()
+	if err != nil || cache == nil {
+		return err
+	}
+
+	fmt.Printf("\nSource cache: %s (%s in %d sources)\n", cache.root, human(cache.size()), len(cache.entries))
+
+	var maxAge time.Duration
+	var maxSize int64
+	if value, _, exists := parser.getArg("srcmaxage"); exists {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return fmt.Errorf("invalid value for --srcmaxage: %s", value)
+		}
+		maxAge = time.Duration(days) * 24 * time.Hour
+	}
+	if value, _, exists := parser.getArg("srcmaxsize"); exists {
+		mib, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || mib < 0 {
+			return fmt.Errorf("invalid value for --srcmaxsize: %s", value)
+		}
+		maxSize = mib * 1024 * 1024
+	}
+
+	var keys []string
+	if maxAge > 0 || maxSize > 0 {
+		keys = srcCacheExpired(cache.entries, time.Now(), maxAge, maxSize)
+	} else if continueTask("Do you want to remove ALL cached sources?", removeAll) {
+		for key := range cache.entries {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	fmt.Println("removing sources from cache...")
+	freed, err := cache.remove(keys)
+	fmt.Printf("removed %d sources, freed %s\n", len(keys), human(freed))
+	return err
+}
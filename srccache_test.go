@@ -0,0 +1,110 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	gosrc "github.com/Morganamilo/go-srcinfo"
+)
+
+func TestCachedSource(t *testing.T) {
+	testCases := []struct {
+		source string
+		name   string
+		key    string
+	}{
+		{"https://example.com/foo-1.0.tar.gz", "foo-1.0.tar.gz", "https://example.com/foo-1.0.tar.gz"},
+		{"foo.tar.gz::https://example.com/v1.0.tar.gz", "foo.tar.gz", "https://example.com/v1.0.tar.gz"},
+		{"git+https://github.com/foo/bar.git#tag=v1.0", "bar", "git+https://github.com/foo/bar.git"},
+		{"git+https://github.com/foo/bar.git?signed#commit=abc", "bar", "git+https://github.com/foo/bar.git"},
+		{"baz::git://example.com/bar/", "baz", "git://example.com/bar/"},
+		{"hg+https://example.com/repo/", "repo", "hg+https://example.com/repo/"},
+	}
+
+	for _, tc := range testCases {
+		name, key, ok := cachedSource(tc.source)
+		if !ok {
+			t.Fatalf("%s: expected the source to be cached", tc.source)
+		}
+		if _, expected, _ := cachedSource(tc.key); name != tc.name || key != expected {
+			t.Fatalf("%s: expected %s keyed by %s, got %s", tc.source, tc.name, tc.key, name)
+		}
+	}
+
+	if _, _, ok := cachedSource("foo.patch"); ok {
+		t.Fatalf("expected local files not to be cached")
+	}
+}
+
+func TestSrcCacheExpired(t *testing.T) {
+	now := time.Unix(100*24*60*60, 0)
+	day := int64(24 * 60 * 60)
+	entries := map[string]*srcCacheEntry{
+		"old":    {Size: 10, Used: now.Unix() - 40*day},
+		"recent": {Size: 30, Used: now.Unix() - 2*day},
+		"middle": {Size: 20, Used: now.Unix() - 10*day},
+		"new":    {Size: 40, Used: now.Unix()},
+	}
+
+	testCases := []struct {
+		maxAge   time.Duration
+		maxSize  int64
+		expected []string
+	}{
+		{0, 0, []string{}},
+		{30 * 24 * time.Hour, 0, []string{"old"}},
+		{0, 70, []string{"old", "middle"}},
+		{5 * 24 * time.Hour, 100, []string{"old", "middle"}},
+		{0, 1, []string{"old", "middle", "recent", "new"}},
+	}
+
+	for _, tc := range testCases {
+		expired := srcCacheExpired(entries, now, tc.maxAge, tc.maxSize)
+		if !reflect.DeepEqual(expired, tc.expected) {
+			t.Fatalf("%v %d: expected %v, got %v", tc.maxAge, tc.maxSize, tc.expected, expired)
+		}
+	}
+}
+
+func TestSrcCacheLock(t *testing.T) {
+	cache := &srcCache{locks: make(map[string]*sync.Mutex)}
+
+	source := func(sources ...string) *gosrc.Srcinfo {
+		srcinfo := &gosrc.Srcinfo{}
+		for _, source := range sources {
+			srcinfo.Source = append(srcinfo.Source, gosrc.ArchString{Value: source})
+		}
+		return srcinfo
+	}
+
+	foo := source("git+https://example.com/lib.git#tag=1", "https://example.com/foo.tar.gz")
+	bar := source("https://example.com/bar.tar.gz", "git+https://example.com/lib.git#tag=2")
+	baz := source("https://example.com/baz.tar.gz", "local.patch")
+
+	unlock := cache.lock(foo)
+
+	// Bases not sharing a source are not held up
+	cache.lock(baz)()
+
+	locked := make(chan struct{})
+	go func() {
+		cache.lock(bar)()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatalf("Expected bar to wait for the source it shares with foo")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected bar to get the lock once foo released it")
+	}
+}
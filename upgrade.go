@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"sort"
 	"sync"
 	"unicode"
@@ -12,10 +13,11 @@ import (
 
 // upgrade type describes a system upgrade.
 type upgrade struct {
-	Name          string
-	Repository    string
-	LocalVersion  string
-	RemoteVersion string
+	Name          string `json:"name"`
+	Repository    string `json:"repository"`
+	LocalVersion  string `json:"localVersion"`
+	RemoteVersion string `json:"remoteVersion"`
+	Devel         bool   `json:"devel"`
 }
 
 // upSlice is a slice of Upgrades
@@ -108,7 +110,8 @@ func getVersionDiff(oldVersion, newVersion string) (left, right string) {
 }
 
 // upList returns lists of packages to upgrade from each source.
-func upList(warnings *aurWarnings) (upSlice, upSlice, error) {
+// Progress messages are written to out.
+func upList(warnings *aurWarnings, out io.Writer) (upSlice, upSlice, error) {
 	local, remote, _, remoteNames, err := filterPackages()
 	if err != nil {
 		return nil, nil, err
@@ -124,7 +127,7 @@ func upList(warnings *aurWarnings) (upSlice, upSlice, error) {
 	aurdata := make(map[string]*rpc.Pkg)
 
 	if mode == modeAny || mode == modeRepo {
-		fmt.Println(bold(cyan("::") + bold(" Searching databases for updates...")))
+		fmt.Fprintln(out, bold(cyan("::")+bold(" Searching databases for updates...")))
 		wg.Add(1)
 		go func() {
 			repoUp, err = upRepo(local)
@@ -134,7 +137,11 @@ func upList(warnings *aurWarnings) (upSlice, upSlice, error) {
 	}
 
 	if mode == modeAny || mode == modeAUR {
-		fmt.Println(bold(cyan("::") + bold(" Searching AUR for updates...")))
+		if config.Offline {
+			fmt.Fprintln(out, bold(cyan("::")+bold(" Searching cached AUR data for updates...")))
+		} else {
+			fmt.Fprintln(out, bold(cyan("::")+bold(" Searching AUR for updates...")))
+		}
 
 		var _aurdata []*rpc.Pkg
 		_aurdata, err = aurInfo(remoteNames, warnings)
@@ -146,16 +153,16 @@ func upList(warnings *aurWarnings) (upSlice, upSlice, error) {
 
 			wg.Add(1)
 			go func() {
-				aurUp, err = upAUR(remote, aurdata)
+				aurUp, err = upAUR(remote, aurdata, out)
 				errs.Add(err)
 				wg.Done()
 			}()
 
-			if config.Devel {
-				fmt.Println(bold(cyan("::") + bold(" Checking development packages...")))
+			if config.Devel && !config.Offline {
+				fmt.Fprintln(out, bold(cyan("::")+bold(" Checking development packages...")))
 				wg.Add(1)
 				go func() {
-					develUp = upDevel(remote, aurdata)
+					develUp = upDevel(remote, aurdata, out)
 					wg.Done()
 				}()
 			}
@@ -164,7 +171,7 @@ func upList(warnings *aurWarnings) (upSlice, upSlice, error) {
 
 	wg.Wait()
 
-	printLocalNewerThanAUR(remote, aurdata)
+	printLocalNewerThanAUR(remote, aurdata, out)
 
 	if develUp != nil {
 		names := make(stringSet)
@@ -180,10 +187,19 @@ func upList(warnings *aurWarnings) (upSlice, upSlice, error) {
 		aurUp = develUp
 	}
 
+	if p, err := loadPins(); err != nil {
+		errs.Add(err)
+	} else {
+		var held []heldUpgrade
+		aurUp, held = p.hold(aurUp)
+		warnings.Held = append(warnings.Held, held...)
+		printHeldUpgrades(held, out)
+	}
+
 	return aurUp, repoUp, errs.Return()
 }
 
-func upDevel(remote []alpm.Package, aurdata map[string]*rpc.Pkg) (toUpgrade upSlice) {
+func upDevel(remote []alpm.Package, aurdata map[string]*rpc.Pkg, out io.Writer) (toUpgrade upSlice) {
 	toUpdate := make([]alpm.Package, 0)
 	toRemove := make([]string, 0)
 
@@ -221,9 +237,9 @@ func upDevel(remote []alpm.Package, aurdata map[string]*rpc.Pkg) (toUpgrade upSl
 
 	for _, pkg := range toUpdate {
 		if pkg.ShouldIgnore() {
-			printIgnoringPackage(pkg, "latest-commit")
+			printIgnoringPackage(pkg, "latest-commit", out)
 		} else {
-			toUpgrade = append(toUpgrade, upgrade{pkg.Name(), "devel", pkg.Version(), "latest-commit"})
+			toUpgrade = append(toUpgrade, upgrade{pkg.Name(), "devel", pkg.Version(), "latest-commit", true})
 		}
 	}
 
@@ -233,7 +249,7 @@ func upDevel(remote []alpm.Package, aurdata map[string]*rpc.Pkg) (toUpgrade upSl
 
 // upAUR gathers foreign packages and checks if they have new versions.
 // Output: Upgrade type package list.
-func upAUR(remote []alpm.Package, aurdata map[string]*rpc.Pkg) (upSlice, error) {
+func upAUR(remote []alpm.Package, aurdata map[string]*rpc.Pkg, out io.Writer) (upSlice, error) {
 	toUpgrade := make(upSlice, 0)
 
 	for _, pkg := range remote {
@@ -245,9 +261,9 @@ func upAUR(remote []alpm.Package, aurdata map[string]*rpc.Pkg) (upSlice, error)
 		if (config.TimeUpdate && (int64(aurPkg.LastModified) > pkg.BuildDate().Unix())) ||
 			(alpm.VerCmp(pkg.Version(), aurPkg.Version) < 0) {
 			if pkg.ShouldIgnore() {
-				printIgnoringPackage(pkg, aurPkg.Version)
+				printIgnoringPackage(pkg, aurPkg.Version, out)
 			} else {
-				toUpgrade = append(toUpgrade, upgrade{aurPkg.Name, "aur", pkg.Version(), aurPkg.Version})
+				toUpgrade = append(toUpgrade, upgrade{aurPkg.Name, "aur", pkg.Version(), aurPkg.Version, false})
 			}
 		}
 	}
@@ -255,10 +271,10 @@ func upAUR(remote []alpm.Package, aurdata map[string]*rpc.Pkg) (upSlice, error)
 	return toUpgrade, nil
 }
 
-func printIgnoringPackage(pkg alpm.Package, newPkgVersion string) {
+func printIgnoringPackage(pkg alpm.Package, newPkgVersion string, out io.Writer) {
 	left, right := getVersionDiff(pkg.Version(), newPkgVersion)
 
-	fmt.Printf("%s %s: ignoring package upgrade (%s => %s)\n",
+	fmt.Fprintf(out, "%s %s: ignoring package upgrade (%s => %s)\n",
 		yellow(bold(smallArrow)),
 		cyan(pkg.Name()),
 		left, right,
@@ -266,7 +282,7 @@ func printIgnoringPackage(pkg alpm.Package, newPkgVersion string) {
 }
 
 func printLocalNewerThanAUR(
-	remote []alpm.Package, aurdata map[string]*rpc.Pkg) {
+	remote []alpm.Package, aurdata map[string]*rpc.Pkg, out io.Writer) {
 	for _, pkg := range remote {
 		aurPkg, ok := aurdata[pkg.Name()]
 		if !ok {
@@ -276,7 +292,7 @@ func printLocalNewerThanAUR(
 		left, right := getVersionDiff(pkg.Version(), aurPkg.Version)
 
 		if !isDevelName(pkg.Name()) && alpm.VerCmp(pkg.Version(), aurPkg.Version) > 0 {
-			fmt.Printf("%s %s: local (%s) is newer than AUR (%s)\n",
+			fmt.Fprintf(out, "%s %s: local (%s) is newer than AUR (%s)\n",
 				yellow(bold(smallArrow)),
 				cyan(pkg.Name()),
 				left, right,
@@ -316,6 +332,7 @@ func upRepo(local []alpm.Package) (upSlice, error) {
 			pkg.DB().Name(),
 			localVer,
 			pkg.Version(),
+			false,
 		})
 		return nil
 	})